@@ -0,0 +1,261 @@
+package hjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valueKind returns a description of the type of value, used in error
+// messages.
+func valueKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case *OrderedMap:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+// String returns the value of c, if c contains a string. Otherwise an error
+// is returned.
+func (c *Node) String() (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("Node is nil")
+	}
+	s, ok := c.Value.(string)
+	if !ok {
+		return "", fmt.Errorf("Expected a string, found %s", valueKind(c.Value))
+	}
+	return s, nil
+}
+
+// Float64 returns the value of c, if c contains a number of the type float64
+// or json.Number. Otherwise an error is returned.
+func (c *Node) Float64() (float64, error) {
+	if c == nil {
+		return 0, fmt.Errorf("Node is nil")
+	}
+	switch v := c.Value.(type) {
+	case float64:
+		return v, nil
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid number %s", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("Expected a number, found %s", valueKind(c.Value))
+}
+
+// Int64 returns the value of c, if c contains a number of the type float64 or
+// json.Number without any fractional part, that fits in an int64. Otherwise an
+// error is returned.
+func (c *Node) Int64() (int64, error) {
+	if c == nil {
+		return 0, fmt.Errorf("Node is nil")
+	}
+	if n, ok := c.Value.(json.Number); ok {
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	f, err := c.Float64()
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("Expected an integer, found %v", c.Value)
+	}
+	return int64(f), nil
+}
+
+// Bool returns the value of c, if c contains a bool. Otherwise an error is
+// returned.
+func (c *Node) Bool() (bool, error) {
+	if c == nil {
+		return false, fmt.Errorf("Node is nil")
+	}
+	b, ok := c.Value.(bool)
+	if !ok {
+		return false, fmt.Errorf("Expected a bool, found %s", valueKind(c.Value))
+	}
+	return b, nil
+}
+
+// Duration returns the value of c as a time.Duration. A string is parsed
+// using time.ParseDuration(), for example "1m30s". A number is interpreted as
+// a number of nanoseconds, which is how time.Duration is stored by Marshal().
+// Other types cause an error to be returned.
+func (c *Node) Duration() (time.Duration, error) {
+	if c == nil {
+		return 0, fmt.Errorf("Node is nil")
+	}
+	if s, ok := c.Value.(string); ok {
+		return time.ParseDuration(s)
+	}
+	switch c.Value.(type) {
+	case float64, json.Number:
+		i, err := c.Int64()
+		return time.Duration(i), err
+	}
+	return 0, fmt.Errorf("Expected a duration, found %s", valueKind(c.Value))
+}
+
+// lookup returns the element identified by path, or nil if no element is
+// found. If path starts with "/" it is a JSON Pointer (RFC 6901), otherwise it
+// is a list of keys and array indices separated by dots, for example
+// "servers.0.port". An error is returned if path goes through a value that is
+// not an object or an array, or if an array index is invalid.
+func (c *Node) lookup(path string) (*Node, error) {
+	if strings.HasPrefix(path, "/") {
+		return c.Get(path)
+	}
+
+	node := c
+	if path == "" {
+		return node, nil
+	}
+	tokens := strings.Split(path, ".")
+	for i, token := range tokens {
+		var elem interface{}
+		switch cont := node.Value.(type) {
+		case *OrderedMap:
+			elem = cont.Map[token]
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("Invalid array index %q at %q", token,
+					strings.Join(tokens[:i], "."))
+			}
+			if index < len(cont) {
+				elem = cont[index]
+			}
+		case nil:
+		default:
+			return nil, fmt.Errorf("Expected an object or an array at %q, found %s",
+				strings.Join(tokens[:i], "."), valueKind(node.Value))
+		}
+		if node = elemNode(elem); node == nil {
+			return nil, nil
+		}
+	}
+	return node, nil
+}
+
+// getNode returns the element identified by path, or nil if the element is
+// not found or contains null.
+func (c *Node) getNode(path string) (*Node, error) {
+	if c == nil {
+		return nil, nil
+	}
+	node, err := c.lookup(path)
+	if err != nil || node == nil || node.Value == nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func pathError(path string, err error) error {
+	return fmt.Errorf("Value at %q: %v", path, err)
+}
+
+// GetString returns the string found at path, or def if path does not exist
+// or the value at path is null. If path starts with "/" it is a JSON Pointer
+// (RFC 6901), otherwise it is a list of keys and array indices separated by
+// dots, for example "servers.0.host". An error is returned if the value at
+// path is not a string.
+func (c *Node) GetString(path string, def string) (string, error) {
+	node, err := c.getNode(path)
+	if node == nil || err != nil {
+		return def, err
+	}
+	s, err := node.String()
+	if err != nil {
+		return def, pathError(path, err)
+	}
+	return s, nil
+}
+
+// GetInt is like GetString, but returns an int. An error is returned if the
+// value at path is not an integer that fits in an int.
+func (c *Node) GetInt(path string, def int) (int, error) {
+	i, err := c.GetInt64(path, int64(def))
+	if err != nil {
+		return def, err
+	}
+	if int64(int(i)) != i {
+		return def, pathError(path, fmt.Errorf("%d does not fit in an int", i))
+	}
+	return int(i), nil
+}
+
+// GetInt64 is like GetString, but returns an int64. An error is returned if
+// the value at path is not an integer that fits in an int64.
+func (c *Node) GetInt64(path string, def int64) (int64, error) {
+	node, err := c.getNode(path)
+	if node == nil || err != nil {
+		return def, err
+	}
+	i, err := node.Int64()
+	if err != nil {
+		return def, pathError(path, err)
+	}
+	return i, nil
+}
+
+// GetFloat64 is like GetString, but returns a float64. An error is returned if
+// the value at path is not a number.
+func (c *Node) GetFloat64(path string, def float64) (float64, error) {
+	node, err := c.getNode(path)
+	if node == nil || err != nil {
+		return def, err
+	}
+	f, err := node.Float64()
+	if err != nil {
+		return def, pathError(path, err)
+	}
+	return f, nil
+}
+
+// GetBool is like GetString, but returns a bool. An error is returned if the
+// value at path is not a bool.
+func (c *Node) GetBool(path string, def bool) (bool, error) {
+	node, err := c.getNode(path)
+	if node == nil || err != nil {
+		return def, err
+	}
+	b, err := node.Bool()
+	if err != nil {
+		return def, pathError(path, err)
+	}
+	return b, nil
+}
+
+// GetDuration is like GetString, but returns a time.Duration. See
+// Node.Duration() for the accepted values.
+func (c *Node) GetDuration(path string, def time.Duration) (time.Duration, error) {
+	node, err := c.getNode(path)
+	if node == nil || err != nil {
+		return def, err
+	}
+	d, err := node.Duration()
+	if err != nil {
+		return def, pathError(path, err)
+	}
+	return d, nil
+}
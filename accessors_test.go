@@ -0,0 +1,132 @@
+package hjson
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var accessorsText = []byte(`{
+  name: app
+  server: {
+    port: 8080
+    ratio: 0.5
+    timeout: 1m30s
+    debug: true
+    nothing: null
+  }
+  servers: [
+    {host: "a", port: 1}
+  ]
+  big: 9007199254740993
+}`)
+
+func TestNodeAccessors(t *testing.T) {
+	for _, useJSONNumber := range []bool{false, true} {
+		var node Node
+		err := UnmarshalWithOptions(accessorsText, &node, DecoderOptions{UseJSONNumber: useJSONNumber})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s, err := node.NK("name").String(); err != nil || s != "app" {
+			t.Errorf("Unexpected string %q %v", s, err)
+		}
+		if i, err := node.NK("server").NK("port").Int64(); err != nil || i != 8080 {
+			t.Errorf("Unexpected int %d %v", i, err)
+		}
+		if f, err := node.NK("server").NK("ratio").Float64(); err != nil || f != 0.5 {
+			t.Errorf("Unexpected float %v %v", f, err)
+		}
+		if b, err := node.NK("server").NK("debug").Bool(); err != nil || !b {
+			t.Errorf("Unexpected bool %v %v", b, err)
+		}
+		if d, err := node.NK("server").NK("timeout").Duration(); err != nil || d != 90*time.Second {
+			t.Errorf("Unexpected duration %v %v", d, err)
+		}
+		if d, err := node.NK("server").NK("port").Duration(); err != nil || d != 8080 {
+			t.Errorf("Unexpected duration %v %v", d, err)
+		}
+		if useJSONNumber {
+			if i, err := node.NK("big").Int64(); err != nil || i != 9007199254740993 {
+				t.Errorf("Unexpected int %d %v", i, err)
+			}
+		}
+
+		if _, err := node.NK("server").NK("ratio").Int64(); err == nil {
+			t.Error("Expected an error for a non-integer")
+		}
+		if _, err := node.NK("name").Float64(); err == nil || err.Error() != "Expected a number, found string" {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if _, err := node.NK("server").String(); err == nil || err.Error() != "Expected a string, found object" {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if _, err := node.NK("missing").Bool(); err == nil {
+			t.Error("Expected an error for a nil Node")
+		}
+	}
+}
+
+func TestNodeGetters(t *testing.T) {
+	var node Node
+	if err := Unmarshal(accessorsText, &node); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := node.GetInt("server.port", 1); err != nil || v != 8080 {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetInt("/server/port", 1); err != nil || v != 8080 {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetInt("server.missing", 1); err != nil || v != 1 {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetInt("server.nothing", 2); err != nil || v != 2 {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetInt64("servers.0.port", 3); err != nil || v != 1 {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetString("servers.0.host", ""); err != nil || v != "a" {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetString("servers.1.host", "x"); err != nil || v != "x" {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetFloat64("server.ratio", 1); err != nil || v != 0.5 {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetBool("server.debug", false); err != nil || !v {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetDuration("server.timeout", time.Second); err != nil || v != 90*time.Second {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	if v, err := node.GetDuration("server.idle", time.Second); err != nil || v != time.Second {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+	var nilNode *Node
+	if v, err := nilNode.GetString("name", "def"); err != nil || v != "def" {
+		t.Errorf("Unexpected value %v %v", v, err)
+	}
+
+	testCases := []struct {
+		get func() error
+		err string
+	}{
+		{func() error { _, err := node.GetInt("name", 1); return err }, `Value at "name": Expected a number, found string`},
+		{func() error { _, err := node.GetInt("server.ratio", 1); return err }, `Value at "server.ratio": Expected an integer, found 0.5`},
+		{func() error { _, err := node.GetString("server.port", ""); return err }, `Value at "server.port": Expected a string, found number`},
+		{func() error { _, err := node.GetBool("/servers/0", false); return err }, `Value at "/servers/0": Expected a bool, found object`},
+		{func() error { _, err := node.GetDuration("name", 0); return err }, `Value at "name": time: invalid duration`},
+		{func() error { _, err := node.GetFloat64("name.x", 0); return err }, `Expected an object or an array at "name", found string`},
+		{func() error { _, err := node.GetFloat64("servers.x", 0); return err }, `Invalid array index "x" at "servers"`},
+	}
+	for _, tc := range testCases {
+		if err := tc.get(); err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+			t.Errorf("Expected error %q, got %v", tc.err, err)
+		}
+	}
+}
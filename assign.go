@@ -0,0 +1,799 @@
+package hjson
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The functions in this file store values that have been parsed by
+// hjsonParser into destinations of any type, following the same rules as
+// json.Unmarshal(). The values can be of these types:
+//
+//	nil
+//	bool
+//	string
+//	json.Number
+//	float64
+//	[]interface{}
+//	*hjson.OrderedMap
+//	*hjson.Node (containing any of the types above)
+//	hjson.RawMessage (only for destinations of type hjson.RawMessage)
+
+var unmarshalerJSON = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// assignContext is used to add the struct field path to type errors.
+type assignContext struct {
+	structType reflect.Type
+	fieldStack []string
+}
+
+// saveAssignError saves the first err it is called with, for reporting at the
+// end of the assignment.
+func (p *hjsonParser) saveAssignError(err error) {
+	if p.assignErr == nil {
+		p.assignErr = p.addErrorContext(err)
+	}
+}
+
+// addErrorContext returns a new error enhanced with information from
+// p.assignCtx.
+func (p *hjsonParser) addErrorContext(err error) error {
+	if p.assignCtx.structType != nil || len(p.assignCtx.fieldStack) > 0 {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			typeErr.Struct = p.assignCtx.structType.Name()
+			typeErr.Field = strings.Join(p.assignCtx.fieldStack, ".")
+		}
+	}
+	return err
+}
+
+func (p *hjsonParser) typeError(what string, t reflect.Type) {
+	p.saveAssignError(&json.UnmarshalTypeError{Value: what, Type: t})
+}
+
+// assignRoot stores value in the destination pointed to by v. Returns the
+// first error found.
+func (p *hjsonParser) assignRoot(value interface{}, v reflect.Value) error {
+	p.assignErr = nil
+	p.assignCtx = assignContext{}
+	if err := p.assign(value, v); err != nil {
+		return p.addErrorContext(err)
+	}
+	return p.assignErr
+}
+
+func (p *hjsonParser) assign(value interface{}, v reflect.Value) error {
+	if !v.IsValid() {
+		// Nowhere to store the value, for example an unknown struct field.
+		return nil
+	}
+
+	// src is passed on so that UnmarshalHjson() can be called with the Node
+	// tree, if there is one.
+	src := value
+	if node, ok := value.(*Node); ok {
+		value = node.Value
+		if sp, ok := p.nodeSpans[node]; ok {
+			value = p.sourceValue(value, p.data[sp[0]:sp[1]], v)
+		}
+	}
+
+	switch val := value.(type) {
+	case RawMessage:
+		p.assignRaw(val, v)
+		return nil
+	case *OrderedMap:
+		return p.assignObject(val, src, v)
+	case []interface{}:
+		return p.assignArray(val, src, v)
+	}
+	return p.assignLiteral(value, src, v)
+}
+
+// sourceValue returns the value to store in v for a value that was read into a
+// Node from the input text src, so that the result is the same as if v had
+// been the destination when parsing. Quoteless literals are read as strings
+// for string destinations, numbers keep their exact text, and destinations of
+// type hjson.RawMessage get src.
+func (p *hjsonParser) sourceValue(value interface{}, src []byte, v reflect.Value) interface{} {
+	t := v.Type()
+	if isRawMessage(v, t) {
+		return append(RawMessage{}, src...)
+	}
+	switch value.(type) {
+	case nil, bool, float64, json.Number:
+	default:
+		return value
+	}
+
+	if value == nil && t.Kind() == reflect.Ptr {
+		return nil
+	}
+	if _, newT := unravelDestination(v, t); newT != nil && newT.Kind() == reflect.String ||
+		t.Implements(unmarshalerText) || v.CanAddr() && v.Addr().Type().Implements(unmarshalerText) {
+
+		return string(src)
+	}
+	if _, ok := value.(float64); ok {
+		return json.Number(src)
+	}
+	return value
+}
+
+// indirect walks down v allocating pointers as needed, until it gets to a
+// non-pointer. If it encounters an Unmarshaler, indirect stops and returns
+// that. If decodingNull is true, indirect stops at the first settable pointer
+// so it can be set to nil.
+func indirect(v reflect.Value, decodingNull bool) (
+	Unmarshaler,
+	json.Unmarshaler,
+	encoding.TextUnmarshaler,
+	reflect.Value,
+) {
+	// Issue #24153 indicates that it is generally not a guaranteed property
+	// that you may round-trip a reflect.Value by calling Value.Addr().Elem()
+	// and expect the value to still be settable for values derived from
+	// unexported embedded struct fields.
+	//
+	// The logic below effectively does this when it first addresses the value
+	// (to satisfy possible pointer methods) and continues to dereference
+	// subsequent pointers as necessary.
+	//
+	// After the first round-trip, we set v back to the original value to
+	// preserve the original RW flags contained in reflect.Value.
+	v0 := v
+	haveAddr := false
+
+	// If v is a named type and is addressable, start with its address, so that
+	// if the type has pointer methods, we find them.
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		haveAddr = true
+		v = v.Addr()
+	}
+	for a := 0; a < maxPointerDepth; a++ {
+		// Load value from interface, but only if the result will be usefully
+		// addressable.
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!decodingNull || e.Elem().Kind() == reflect.Ptr) {
+				haveAddr = false
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+
+		if decodingNull && v.CanSet() {
+			break
+		}
+
+		// Prevent infinite loop if v is an interface pointing to its own address:
+		//     var v interface{}
+		//     v = &v
+		if v.Elem().Kind() == reflect.Interface && v.Elem().Elem() == v {
+			v = v.Elem()
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(Unmarshaler); ok {
+				return u, nil, nil, reflect.Value{}
+			}
+			if u, ok := v.Interface().(json.Unmarshaler); ok {
+				return nil, u, nil, reflect.Value{}
+			}
+			if !decodingNull {
+				if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+					return nil, nil, u, reflect.Value{}
+				}
+			}
+		}
+
+		if haveAddr {
+			v = v0 // restore original value after round-trip Value.Addr().Elem()
+			haveAddr = false
+		} else {
+			v = v.Elem()
+		}
+	}
+	return nil, nil, nil, v
+}
+
+// useUnmarshalerJSON converts value to JSON and calls UnmarshalJSON() on u.
+func useUnmarshalerJSON(u json.Unmarshaler, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return u.UnmarshalJSON(b)
+}
+
+func (p *hjsonParser) assignArray(arr []interface{}, src interface{}, v reflect.Value) error {
+	uh, u, ut, pv := indirect(v, false)
+	if uh != nil {
+		return uh.UnmarshalHjson(p.nodeTree(src))
+	}
+	if u != nil {
+		return useUnmarshalerJSON(u, arr)
+	}
+	if ut != nil {
+		p.typeError("array", v.Type())
+		return nil
+	}
+	v = pv
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			value, err := p.valueInterface(arr)
+			if err != nil {
+				p.saveAssignError(err)
+				return nil
+			}
+			v.Set(reflect.ValueOf(value))
+			return nil
+		}
+		p.typeError("array", v.Type())
+		return nil
+	case reflect.Array, reflect.Slice:
+	default:
+		p.typeError("array", v.Type())
+		return nil
+	}
+
+	if v.Kind() == reflect.Slice {
+		oldLen := v.Len()
+		if v.Cap() < len(arr) {
+			newV := reflect.MakeSlice(v.Type(), oldLen, len(arr))
+			reflect.Copy(newV, v)
+			v.Set(newV)
+		}
+		v.SetLen(len(arr))
+		// Any elements after the old length might contain old values.
+		for i := oldLen; i < len(arr); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	}
+
+	for i, elem := range arr {
+		if i >= v.Len() {
+			// Ran out of fixed array: skip.
+			break
+		}
+		if err := p.assign(elem, v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	if v.Kind() == reflect.Array {
+		// Zero the rest of the array.
+		for i := len(arr); i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	} else if len(arr) == 0 {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	}
+
+	return nil
+}
+
+// objectAssigner is implemented by destinations that store the elements of
+// an object themselves, using p.assign() so that the options of p also apply
+// to the elements. objectAssigner has precedence over json.Unmarshaler.
+type objectAssigner interface {
+	assignObject(p *hjsonParser, om *OrderedMap) error
+}
+
+func (p *hjsonParser) assignObject(om *OrderedMap, src interface{}, v reflect.Value) error {
+	uh, u, ut, pv := indirect(v, false)
+	if uh != nil {
+		return uh.UnmarshalHjson(p.nodeTree(src))
+	}
+	if u != nil {
+		if oa, ok := u.(objectAssigner); ok {
+			return oa.assignObject(p, om)
+		}
+		return useUnmarshalerJSON(u, om)
+	}
+	if ut != nil {
+		p.typeError("object", v.Type())
+		return nil
+	}
+	v = pv
+	t := v.Type()
+
+	var stm structFieldMap
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			value, err := p.valueInterface(om)
+			if err != nil {
+				p.saveAssignError(err)
+				return nil
+			}
+			v.Set(reflect.ValueOf(value))
+			return nil
+		}
+		p.typeError("object", t)
+		return nil
+	case reflect.Map:
+		// Map key must either have string kind, have an integer kind, or be an
+		// encoding.TextUnmarshaler.
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(unmarshalerText) {
+				p.typeError("object", t)
+				return nil
+			}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+	case reflect.Struct:
+		var ok bool
+		stm, ok = p.structTypeCache[t]
+		if !ok {
+			stm = getStructFieldInfoMap(t)
+			p.structTypeCache[t] = stm
+		}
+	default:
+		p.typeError("object", t)
+		return nil
+	}
+
+	var mapElem reflect.Value
+	origCtx := p.assignCtx
+	// The names of the struct fields found in om.
+	present := map[string]bool{}
+
+	for _, key := range om.Keys {
+		value := om.Map[key]
+
+		var subv reflect.Value
+		if v.Kind() == reflect.Map {
+			elemType := t.Elem()
+			if !mapElem.IsValid() {
+				mapElem = reflect.New(elemType).Elem()
+			} else {
+				mapElem.Set(reflect.Zero(elemType))
+			}
+			subv = mapElem
+		} else if sfi, ok := stm.getField(key); ok {
+			subv = p.structField(v, sfi)
+			present[sfi.name] = true
+			p.assignCtx.fieldStack = append(p.assignCtx.fieldStack, sfi.name)
+			p.assignCtx.structType = t
+
+			if sfi.quoted {
+				var err error
+				if value, err = unquoteField(value, subv); err != nil {
+					p.saveAssignError(err)
+					subv = reflect.Value{}
+				}
+			}
+		} else if p.DisallowUnknownFields {
+			p.saveAssignError(fmt.Errorf("json: unknown field %q", key))
+		}
+
+		if err := p.assign(value, subv); err != nil {
+			return err
+		}
+
+		// Write value back to map; if using struct, subv points into struct
+		// already.
+		if v.Kind() == reflect.Map {
+			kt := t.Key()
+			var kv reflect.Value
+			if reflect.PtrTo(kt).Implements(unmarshalerText) {
+				kv = reflect.New(kt)
+				if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+					p.saveAssignError(err)
+					continue
+				}
+				kv = kv.Elem()
+			} else {
+				switch kt.Kind() {
+				case reflect.String:
+					kv = reflect.New(kt).Elem()
+					kv.SetString(key)
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+					n, err := strconv.ParseInt(key, 10, 64)
+					if err != nil || kt.OverflowInt(n) {
+						p.typeError("number "+key, kt)
+						continue
+					}
+					kv = reflect.New(kt).Elem()
+					kv.SetInt(n)
+				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+					n, err := strconv.ParseUint(key, 10, 64)
+					if err != nil || kt.OverflowUint(n) {
+						p.typeError("number "+key, kt)
+						continue
+					}
+					kv = reflect.New(kt).Elem()
+					kv.SetUint(n)
+				}
+			}
+			if kv.IsValid() {
+				v.SetMapIndex(kv, subv)
+			}
+		}
+
+		p.assignCtx = origCtx
+	}
+
+	if v.Kind() == reflect.Struct {
+		p.assignDefaults(v, present)
+	}
+
+	return nil
+}
+
+// structField returns the field described by sfi in the struct v. The field
+// might be found on the root struct or in embedded structs, in which case nil
+// pointers to embedded structs are set to newly allocated values. Returns an
+// invalid reflect.Value if the field cannot be set.
+func (p *hjsonParser) structField(v reflect.Value, sfi structFieldInfo) reflect.Value {
+	subv := v
+	for _, i := range sfi.indexPath {
+		if subv.Kind() == reflect.Ptr {
+			if subv.IsNil() {
+				// If a struct embeds a pointer to an unexported type, it is not
+				// possible to set a newly allocated value since the field is
+				// unexported.
+				if !subv.CanSet() {
+					p.saveAssignError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v",
+						subv.Type().Elem()))
+					return reflect.Value{}
+				}
+				subv.Set(reflect.New(subv.Type().Elem()))
+			}
+			subv = subv.Elem()
+		}
+		subv = subv.Field(i)
+	}
+	return subv
+}
+
+// assignDefaults stores the values in the "default" struct tags in the fields
+// of the struct v that are not in present and still hold their zero values, so
+// that values from an earlier Unmarshal() into v are kept. Fields of struct
+// types that are not in present get the default values of their own fields.
+func (p *hjsonParser) assignDefaults(v reflect.Value, present map[string]bool) {
+	t := v.Type()
+	sds, ok := p.defaultsCache[t]
+	if !ok {
+		sds = getStructDefaults(t, p.parseDefault)
+		p.defaultsCache[t] = sds
+	}
+
+	for _, sd := range sds {
+		if present[sd.name] {
+			continue
+		}
+		subv := p.structField(v, sd.structFieldInfo)
+		if !subv.IsValid() {
+			continue
+		}
+		if sd.defaultValue == "" {
+			p.assignDefaults(subv, nil)
+			continue
+		}
+		if !isZeroValue(subv) {
+			continue
+		}
+		if sd.err != nil {
+			p.saveAssignError(fmt.Errorf("Invalid default value for field %s in %v: %v",
+				sd.name, t, sd.err))
+			continue
+		}
+		// The parsed value is shared by all structs of the type t, so each
+		// struct gets its own copy.
+		value := cloneValue(sd.value)
+		if raw, ok := value.(RawMessage); ok {
+			value = append(RawMessage{}, raw...)
+		}
+		// Fields of type Node or *Node get the comments from the tag too, as
+		// when they are the destination of Unmarshal().
+		if node, ok := value.(*Node); ok {
+			if nv := reflect.ValueOf(node); subv.Type() == nv.Type() {
+				subv.Set(nv)
+				continue
+			} else if subv.Type() == nv.Type().Elem() {
+				subv.Set(nv.Elem())
+				continue
+			}
+		}
+		if err := p.assign(value, subv); err != nil {
+			p.saveAssignError(err)
+		}
+	}
+}
+
+// parseDefault parses text, the "default" struct tag of a field of type t,
+// using the same options as p. The result is checked by storing it in a new
+// value of type t, and can then be stored in any field of type t using
+// p.assign().
+func (p *hjsonParser) parseDefault(text string, t reflect.Type) (interface{}, error) {
+	dp := newHjsonParser([]byte(text), p.DecoderOptions)
+	var value interface{}
+	err := dp.unmarshal(reflect.New(t).Interface(), func(rv reflect.Value) (interface{}, error) {
+		dp.resetAt()
+		var err error
+		value, err = dp.rootValue(rv)
+		return value, err
+	})
+	return value, err
+}
+
+// isZeroValue returns true if v holds the zero value of its type.
+func isZeroValue(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// unquoteField converts the string value for a struct field that has the
+// ",string" option into the value that should be stored in the field.
+func unquoteField(value interface{}, field reflect.Value) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		if value == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v",
+			field.Type())
+	}
+
+	t := field.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case s == "null":
+		return nil, nil
+	case t.Kind() == reflect.String:
+		var unquoted string
+		if err := json.Unmarshal([]byte(s), &unquoted); err == nil {
+			return unquoted, nil
+		}
+	case t.Kind() == reflect.Bool:
+		if s == "true" || s == "false" {
+			return s == "true", nil
+		}
+	default:
+		if n, err := tryParseNumber([]byte(s), false, true); err == nil {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v",
+		s, field.Type())
+}
+
+func (p *hjsonParser) assignLiteral(value, src interface{}, v reflect.Value) error {
+	isNull := value == nil
+	uh, u, ut, pv := indirect(v, isNull)
+	if uh != nil {
+		return uh.UnmarshalHjson(p.nodeTree(src))
+	}
+	if u != nil {
+		return useUnmarshalerJSON(u, value)
+	}
+	if ut != nil {
+		s, ok := value.(string)
+		if !ok {
+			p.typeError(literalKind(value), v.Type())
+			return nil
+		}
+		return ut.UnmarshalText([]byte(s))
+	}
+
+	v = pv
+
+	switch c := value.(type) {
+	case nil:
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+			// otherwise, ignore null for primitives/string
+		}
+
+	case bool:
+		switch v.Kind() {
+		case reflect.Bool:
+			v.SetBool(c)
+		case reflect.Interface:
+			if v.NumMethod() == 0 {
+				v.Set(reflect.ValueOf(c))
+			} else {
+				p.typeError("bool", v.Type())
+			}
+		default:
+			p.typeError("bool", v.Type())
+		}
+
+	case string:
+		switch v.Kind() {
+		case reflect.Slice:
+			if v.Type().Elem().Kind() != reflect.Uint8 {
+				p.typeError("string", v.Type())
+				break
+			}
+			b, err := base64.StdEncoding.DecodeString(c)
+			if err != nil {
+				p.saveAssignError(err)
+				break
+			}
+			v.SetBytes(b)
+		case reflect.String:
+			if v.Type() == JSONNumberType {
+				if _, err := tryParseNumber([]byte(c), false, true); err != nil {
+					return fmt.Errorf("json: invalid number literal, trying to unmarshal %q into Number", c)
+				}
+			}
+			v.SetString(c)
+		case reflect.Interface:
+			if v.NumMethod() == 0 {
+				v.Set(reflect.ValueOf(c))
+			} else {
+				p.typeError("string", v.Type())
+			}
+		default:
+			p.typeError("string", v.Type())
+		}
+
+	case json.Number, float64:
+		var s string
+		if n, ok := c.(json.Number); ok {
+			s = string(n)
+		} else {
+			s = strconv.FormatFloat(c.(float64), 'f', -1, 64)
+		}
+		switch v.Kind() {
+		case reflect.Interface:
+			n, err := p.convertNumber(s)
+			if err != nil {
+				p.saveAssignError(err)
+				break
+			}
+			if v.NumMethod() != 0 {
+				p.typeError("number", v.Type())
+				break
+			}
+			v.Set(reflect.ValueOf(n))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v.OverflowInt(n) {
+				p.typeError("number "+s, v.Type())
+				break
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil || v.OverflowUint(n) {
+				p.typeError("number "+s, v.Type())
+				break
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(s, v.Type().Bits())
+			if err != nil || v.OverflowFloat(n) {
+				p.typeError("number "+s, v.Type())
+				break
+			}
+			v.SetFloat(n)
+		default:
+			if v.Kind() == reflect.String && v.Type() == JSONNumberType {
+				v.SetString(s)
+				break
+			}
+			p.typeError("number", v.Type())
+		}
+
+	default:
+		return fmt.Errorf("Unexpected value type: %v", reflect.TypeOf(value))
+	}
+
+	return nil
+}
+
+// literalKind returns the name used in type errors for value.
+func literalKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	}
+	return "number"
+}
+
+// convertNumber converts the number literal s to a float64 or a json.Number
+// depending on the setting of p.UseJSONNumber.
+func (p *hjsonParser) convertNumber(s string) (interface{}, error) {
+	if p.UseJSONNumber {
+		return json.Number(s), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, &json.UnmarshalTypeError{Value: "number " + s, Type: reflect.TypeOf(0.0)}
+	}
+	return f, nil
+}
+
+// valueInterface returns value converted to the types that json.Unmarshal()
+// would store in an empty interface.
+func (p *hjsonParser) valueInterface(value interface{}) (interface{}, error) {
+	switch val := value.(type) {
+	case *Node:
+		return p.valueInterface(val.Value)
+	case *OrderedMap:
+		m := make(map[string]interface{}, len(val.Keys))
+		for _, key := range val.Keys {
+			elem, err := p.valueInterface(val.Map[key])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = elem
+		}
+		return m, nil
+	case []interface{}:
+		arr := make([]interface{}, len(val))
+		for i, elem := range val {
+			var err error
+			if arr[i], err = p.valueInterface(elem); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case json.Number:
+		return p.convertNumber(string(val))
+	case float64:
+		if p.UseJSONNumber {
+			return json.Number(strconv.FormatFloat(val, 'f', -1, 64)), nil
+		}
+	}
+	return value, nil
+}
+
+// nodeTree returns value as a tree of *hjson.Node, like the tree created by
+// Unmarshal() for a destination of type *hjson.Node. If value already is a
+// *hjson.Node it is returned as is.
+func (p *hjsonParser) nodeTree(value interface{}) *Node {
+	switch val := value.(type) {
+	case *Node:
+		return val
+	case *OrderedMap:
+		om := NewOrderedMap()
+		for _, key := range val.Keys {
+			om.Set(key, p.nodeTree(val.Map[key]))
+		}
+		return &Node{Value: om}
+	case []interface{}:
+		arr := make([]interface{}, len(val))
+		for i, elem := range val {
+			arr[i] = p.nodeTree(elem)
+		}
+		return &Node{Value: arr}
+	case json.Number:
+		if !p.UseJSONNumber {
+			if f, err := val.Float64(); err == nil {
+				return &Node{Value: f}
+			}
+		}
+	}
+	return &Node{Value: value}
+}
@@ -0,0 +1,171 @@
+package hjson
+
+import (
+	"reflect"
+)
+
+// Clone returns a deep copy of the Node tree c, including comments and
+// positions. Every *Node, *OrderedMap and []interface{} in the tree is
+// copied, other values are shared with c. Returns nil if c is nil.
+func (c *Node) Clone() *Node {
+	if c == nil {
+		return nil
+	}
+	res := *c
+	if c.Pos != nil {
+		pos := *c.Pos
+		res.Pos = &pos
+	}
+	res.Value = cloneValue(c.Value)
+	return &res
+}
+
+// Clone returns a deep copy of c. Every *Node, *OrderedMap and []interface{}
+// in c is copied, other values are shared with c. Returns nil if c is nil.
+func (c *OrderedMap) Clone() *OrderedMap {
+	if c == nil {
+		return nil
+	}
+	return cloneValue(c).(*OrderedMap)
+}
+
+// cloneValue returns a deep copy of value, if value is a *Node, an
+// *OrderedMap or an []interface{}. Other values are returned as is.
+func cloneValue(value interface{}) interface{} {
+	switch cont := value.(type) {
+	case *Node:
+		return cont.Clone()
+	case *OrderedMap:
+		if cont == nil {
+			return cont
+		}
+		om := &OrderedMap{
+			Keys: make([]string, len(cont.Keys)),
+			Map:  make(map[string]interface{}, len(cont.Map)),
+		}
+		copy(om.Keys, cont.Keys)
+		for key, elem := range cont.Map {
+			om.Map[key] = cloneValue(elem)
+		}
+		return om
+	case []interface{}:
+		if cont == nil {
+			return cont
+		}
+		arr := make([]interface{}, len(cont))
+		for i, elem := range cont {
+			arr[i] = cloneValue(elem)
+		}
+		return arr
+	}
+	return value
+}
+
+// EqualOptions defines options for Equal(), Node.Equal() and
+// OrderedMap.Equal().
+type EqualOptions struct {
+	// IgnoreComments makes comments on Nodes irrelevant for the comparison.
+	// Otherwise both Nodes must have comments with the same text, as returned
+	// by Comments.Text(), at each position. Comment markers and whitespace
+	// are not compared. A Node is only equal to a plain value if the Node has
+	// no comment text.
+	IgnoreComments bool
+	// IgnoreKeyOrder makes the order of the keys in objects irrelevant for the
+	// comparison.
+	IgnoreKeyOrder bool
+	// NumbersNumerically makes numbers of different types (float64,
+	// json.Number and other Go number types) equal if they have the same
+	// numeric value, for example float64(1) and json.Number("1.0"). Otherwise
+	// numbers must have the same type, and json.Number values must have the
+	// same text.
+	NumbersNumerically bool
+}
+
+// Equal returns true if a and b contain the same values. a and b can be Node
+// trees, *OrderedMap, []interface{} or any other values, which are compared
+// using reflect.DeepEqual(). Node positions are never compared.
+func Equal(a, b interface{}, options EqualOptions) bool {
+	aNode, aIsNode := a.(*Node)
+	bNode, bIsNode := b.(*Node)
+	if aIsNode && bIsNode && (aNode == nil || bNode == nil) {
+		return aNode == bNode
+	}
+	if aIsNode && aNode != nil {
+		a = aNode.Value
+	}
+	if bIsNode && bNode != nil {
+		b = bNode.Value
+	}
+	if !options.IgnoreComments {
+		switch {
+		case aIsNode && bIsNode:
+			if !aNode.Cm.sameText(bNode.Cm) {
+				return false
+			}
+		case aIsNode && aNode != nil:
+			if !aNode.Cm.sameText(Comments{}) {
+				return false
+			}
+		case bIsNode && bNode != nil:
+			if !bNode.Cm.sameText(Comments{}) {
+				return false
+			}
+		}
+	}
+
+	switch va := a.(type) {
+	case *OrderedMap:
+		vb, ok := b.(*OrderedMap)
+		if !ok || va == nil || vb == nil {
+			return ok && va == vb
+		}
+		return va.Equal(vb, options)
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !Equal(va[i], vb[i], options) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if options.NumbersNumerically {
+		if na, ok := floatValue(a); ok {
+			nb, ok := floatValue(b)
+			return ok && na == nb
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// Equal returns true if the Node trees c and other contain the same values.
+// See Equal() for details.
+func (c *Node) Equal(other *Node, options EqualOptions) bool {
+	return Equal(c, other, options)
+}
+
+// Equal returns true if c and other contain the same keys and values. See
+// Equal() for details.
+func (c *OrderedMap) Equal(other *OrderedMap, options EqualOptions) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.Len() != other.Len() {
+		return false
+	}
+	for i, key := range c.Keys {
+		if !options.IgnoreKeyOrder && other.Keys[i] != key {
+			return false
+		}
+		elem, ok := other.Map[key]
+		if !ok || !Equal(c.Map[key], elem, options) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package hjson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNodeClone(t *testing.T) {
+	var node Node
+	err := UnmarshalWithOptions([]byte(`{
+  # servers
+  servers: [
+    {host: "a", port: 1}
+  ]
+}`), &node, DecoderOptions{RecordPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := node.Clone()
+	if !clone.Equal(&node, EqualOptions{}) {
+		t.Fatal("Expected the clone to be equal")
+	}
+	if clone.NK("servers").Pos == node.NK("servers").Pos ||
+		*clone.NK("servers").Pos != *node.NK("servers").Pos {
+		t.Error("Expected a copy of the position")
+	}
+
+	clone.NK("servers").Cm.Before = ""
+	clone.NK("servers").NI(0).NK("port").Value = 2.0
+	if _, _, err = clone.NK("servers").NI(0).SetKey("tls", true); err != nil {
+		t.Fatal(err)
+	}
+	if err = clone.NK("servers").Append("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  # servers
+  servers: [
+    {
+      host: a
+      port: 1
+    }
+  ]
+}`
+	if string(b) != expected {
+		t.Errorf("Expected the original to be unchanged:\n%s\n\nGot:\n%s", expected, string(b))
+	}
+
+	if (*Node)(nil).Clone() != nil || (*OrderedMap)(nil).Clone() != nil {
+		t.Error("Expected nil")
+	}
+}
+
+func TestOrderedMapClone(t *testing.T) {
+	om := NewOrderedMapFromSlice([]KeyValue{
+		{"b", NewOrderedMapFromSlice([]KeyValue{{"x", 1}})},
+		{"a", []interface{}{1, 2}},
+	})
+	clone := om.Clone()
+	if !clone.Equal(om, EqualOptions{}) {
+		t.Fatal("Expected the clone to be equal")
+	}
+
+	clone.Map["b"].(*OrderedMap).Set("y", 2)
+	clone.Map["a"].([]interface{})[0] = 3
+	clone.Set("c", 3)
+	if om.Len() != 2 || om.Map["b"].(*OrderedMap).Len() != 1 || om.Map["a"].([]interface{})[0] != 1 {
+		t.Errorf("Expected the original to be unchanged: %v", om)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	parse := func(text string) *Node {
+		var node Node
+		if err := UnmarshalWithOptions([]byte(text), &node, DecoderOptions{UseJSONNumber: true}); err != nil {
+			t.Fatal(err)
+		}
+		return &node
+	}
+
+	a := parse("{\n  # comment\n  a: 1\n  b: [true, null]\n}")
+	comments := parse("{\n  a: 1\n  b: [true, null]\n}")
+	order := parse("{\n  b: [true, null]\n  # comment\n  a: 1\n}")
+	number := parse("{\n  # comment\n  a: 1.0\n  b: [true, null]\n}")
+	text := &Node{Value: "x"}
+	text.Cm.SetText(CommentBefore, "x", CommentSlash)
+
+	testCases := []struct {
+		a, b     interface{}
+		options  EqualOptions
+		expected bool
+	}{
+		{a, a.Clone(), EqualOptions{}, true},
+		{a, comments, EqualOptions{}, false},
+		{a, comments, EqualOptions{IgnoreComments: true}, true},
+		{a, parse("{\n    /* comment */\n\n    a: 1\n    b: [true, null]\n}"), EqualOptions{}, true},
+		{a, order, EqualOptions{}, false},
+		{a, order, EqualOptions{IgnoreKeyOrder: true}, true},
+		{a, number, EqualOptions{}, false},
+		{a, number, EqualOptions{NumbersNumerically: true}, true},
+		{a, parse("{\n  # comment\n  a: 2\n  b: [true, null]\n}"), EqualOptions{NumbersNumerically: true}, false},
+		{a, parse("{\n  # comment\n  a: 1\n  b: [true]\n}"), EqualOptions{}, false},
+		{json.Number("1"), 1.0, EqualOptions{}, false},
+		{json.Number("1"), 1.0, EqualOptions{NumbersNumerically: true}, true},
+		{json.Number("10"), 10, EqualOptions{NumbersNumerically: true}, true},
+		{&Node{Value: "x"}, "x", EqualOptions{}, true},
+		{&Node{Value: "x", Cm: Comments{After: " # x"}}, "x", EqualOptions{}, false},
+		{&Node{Value: "x", Cm: Comments{After: " # x"}}, "x", EqualOptions{IgnoreComments: true}, true},
+		{&Node{Value: "x", Cm: Comments{Before: "\n  "}}, "x", EqualOptions{}, true},
+		{text, &Node{Value: "x", Cm: Comments{Before: "# x\n  "}}, EqualOptions{}, true},
+		{text, &Node{Value: "x", Cm: Comments{Before: "# y\n  "}}, EqualOptions{}, false},
+		{[]interface{}{"x"}, []interface{}{&Node{Value: "x"}}, EqualOptions{}, true},
+		{(*Node)(nil), &Node{}, EqualOptions{}, false},
+		{"1", 1.0, EqualOptions{NumbersNumerically: true}, false},
+	}
+
+	for i, tc := range testCases {
+		if res := Equal(tc.a, tc.b, tc.options); res != tc.expected {
+			t.Errorf("%d: Expected %v, got %v", i, tc.expected, res)
+		}
+		if res := Equal(tc.b, tc.a, tc.options); res != tc.expected {
+			t.Errorf("%d reversed: Expected %v, got %v", i, tc.expected, res)
+		}
+	}
+}
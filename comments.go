@@ -0,0 +1,239 @@
+package hjson
+
+import (
+	"strings"
+)
+
+// CommentStyle is the syntax used for comments written by Comments.SetText().
+type CommentStyle int
+
+const (
+	// CommentHash writes comments starting with #.
+	CommentHash CommentStyle = iota
+	// CommentSlash writes comments starting with //.
+	CommentSlash
+	// CommentBlock writes comments enclosed in /* and */.
+	CommentBlock
+)
+
+// CommentPos identifies one of the fields in Comments.
+type CommentPos int
+
+const (
+	// CommentBefore is Comments.Before, the lines before the value.
+	CommentBefore CommentPos = iota
+	// CommentKey is Comments.Key, between the key and the value.
+	CommentKey
+	// CommentInsideFirst is Comments.InsideFirst, after the opening bracket of
+	// an object or an array.
+	CommentInsideFirst
+	// CommentInsideLast is Comments.InsideLast, the lines before the closing
+	// bracket of an object or an array.
+	CommentInsideLast
+	// CommentAfter is Comments.After, after the value on the same line.
+	CommentAfter
+)
+
+// field returns a pointer to the field in c identified by pos.
+func (c *Comments) field(pos CommentPos) *string {
+	switch pos {
+	case CommentKey:
+		return &c.Key
+	case CommentInsideFirst:
+		return &c.InsideFirst
+	case CommentInsideLast:
+		return &c.InsideLast
+	case CommentAfter:
+		return &c.After
+	}
+	return &c.Before
+}
+
+// Text returns the text of the comments at pos, without any comment markers
+// (#, //, /* and */) or indentation. Each comment line becomes a line in the
+// returned text. Empty lines between comments are kept as a single empty
+// line. Returns an empty string if there are no comments at pos, only
+// whitespace.
+func (c Comments) Text(pos CommentPos) string {
+	return commentText(*c.field(pos))
+}
+
+// SetText replaces the comments at pos with text, written in the specified
+// style. Each line in text becomes a comment line, except for CommentKey,
+// CommentInsideFirst and CommentAfter where all lines are joined into a
+// single line. If text is empty, the comments at pos are removed.
+//
+// The comments set by SetText() do not contain any indentation or trailing
+// line feed. Marshal() adds the line feeds and the indentation for the depth
+// of the Node when the comments are written.
+func (c *Comments) SetText(pos CommentPos, text string, style CommentStyle) {
+	field := c.field(pos)
+	if text == "" {
+		*field = ""
+		c.text &^= 1 << uint(pos)
+		return
+	}
+
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	switch pos {
+	case CommentBefore, CommentInsideLast:
+		*field = commentLines(text, style)
+	case CommentKey:
+		*field = " " + commentLine(text, style)
+		if style == CommentBlock {
+			*field += " "
+		}
+	default:
+		*field = " " + commentLine(text, style)
+	}
+	c.text |= 1 << uint(pos)
+}
+
+// isText returns true if the comments at pos were set by SetText() and have
+// not been replaced by comments in the format used by Unmarshal(), which
+// always end with whitespace. NodeFrom() also adds empty lines to comments set
+// by SetText(), at the start of the comments. An empty line without any
+// comments is stored as "\n".
+func (c Comments) isText(pos CommentPos) bool {
+	txt := *c.field(pos)
+	return c.text&(1<<uint(pos)) != 0 && txt != "" &&
+		(!strings.ContainsAny(txt[len(txt)-1:], " \t\r\n") || txt == "\n")
+}
+
+// sameText returns true if c and other have the same text, as returned by
+// Text(), at every position.
+func (c Comments) sameText(other Comments) bool {
+	for pos := CommentBefore; pos <= CommentAfter; pos++ {
+		if c.Text(pos) != other.Text(pos) {
+			return false
+		}
+	}
+	return true
+}
+
+// copyFrom sets the comments at each of positions to the comments in src.
+func (c *Comments) copyFrom(src Comments, positions ...CommentPos) {
+	for _, pos := range positions {
+		*c.field(pos) = *src.field(pos)
+		c.text = c.text&^(1<<uint(pos)) | src.text&(1<<uint(pos))
+	}
+}
+
+// commentLine returns text as a single comment without any line feed.
+func commentLine(text string, style CommentStyle) string {
+	text = strings.Join(strings.Fields(text), " ")
+	switch style {
+	case CommentSlash:
+		return "// " + text
+	case CommentBlock:
+		return "/* " + strings.Replace(text, "*/", "* /", -1) + " */"
+	}
+	return "# " + text
+}
+
+// commentLines returns text as comment lines separated by line feeds.
+func commentLines(text string, style CommentStyle) string {
+	lines := strings.Split(text, "\n")
+	if style == CommentBlock {
+		text = strings.Replace(text, "*/", "* /", -1)
+		if len(lines) == 1 {
+			return "/* " + text + " */"
+		}
+		return "/*\n" + text + "\n*/"
+	}
+
+	marker := "#"
+	if style == CommentSlash {
+		marker = "//"
+	}
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = marker
+		} else {
+			lines[i] = marker + " " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commentText returns the text of the comments in raw, without any comment
+// markers or indentation.
+func commentText(raw string) string {
+	var lines []string
+	newLines := 0
+	addLine := func(line string) {
+		if newLines > 1 && len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		newLines = 0
+		lines = append(lines, line)
+	}
+
+	for i := 0; i < len(raw); {
+		switch {
+		case raw[i] == '\n':
+			newLines++
+			i++
+		case raw[i] == ' ' || raw[i] == '\t' || raw[i] == '\r':
+			i++
+		case raw[i] == '#' || strings.HasPrefix(raw[i:], "//"):
+			start := i + 1
+			if raw[i] == '/' {
+				start++
+			}
+			end := strings.IndexByte(raw[i:], '\n')
+			if end < 0 {
+				end = len(raw)
+			} else {
+				end += i
+			}
+			addLine(trimCommentLine(raw[start:end]))
+			i = end
+		case strings.HasPrefix(raw[i:], "/*"):
+			end := strings.Index(raw[i+2:], "*/")
+			if end < 0 {
+				end = len(raw)
+			} else {
+				end += i + 2
+			}
+			block := strings.Split(raw[i+2:end], "\n")
+			i = end + 2
+			// Skip the lines containing only the markers.
+			if len(block) > 1 && strings.TrimSpace(block[0]) == "" {
+				block = block[1:]
+			}
+			if len(block) > 1 && strings.TrimSpace(block[len(block)-1]) == "" {
+				block = block[:len(block)-1]
+			}
+			for _, line := range block {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, "*") {
+					line = line[1:]
+				}
+				addLine(trimCommentLine(line))
+			}
+		default:
+			end := strings.IndexAny(raw[i:], " \t\r\n")
+			if end < 0 {
+				end = len(raw)
+			} else {
+				end += i
+			}
+			addLine(raw[i:end])
+			i = end
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// trimCommentLine removes the space after a comment marker, and any trailing
+// whitespace.
+func trimCommentLine(line string) string {
+	line = strings.TrimRight(line, " \t\r")
+	if strings.HasPrefix(line, " ") {
+		line = line[1:]
+	}
+	return line
+}
@@ -0,0 +1,191 @@
+package hjson
+
+import (
+	"testing"
+)
+
+func TestCommentsText(t *testing.T) {
+	txt := []byte(`# head 1
+#head 2
+{
+  # before a
+  // second line
+  a: /* key */ 1 # after a
+  b: [ # inside first
+    1
+    /* block
+     * comment */
+    2
+    # last in array
+  ]
+
+  # before c
+
+  # more c
+  c: 3
+  # last
+}`)
+
+	var node *Node
+	if err := Unmarshal(txt, &node); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path     string
+		pos      CommentPos
+		expected string
+	}{
+		{"", CommentBefore, "head 1\nhead 2"},
+		{"", CommentInsideLast, "last"},
+		{"/a", CommentBefore, "before a\nsecond line"},
+		{"/a", CommentKey, "key"},
+		{"/a", CommentAfter, "after a"},
+		{"/b", CommentBefore, ""},
+		{"/b", CommentInsideFirst, "inside first"},
+		{"/b", CommentInsideLast, "last in array"},
+		{"/b/1", CommentBefore, "block\ncomment"},
+		{"/c", CommentBefore, "before c\n\nmore c"},
+	}
+	for _, tc := range testCases {
+		elem, err := node.Get(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text := elem.Cm.Text(tc.pos); text != tc.expected {
+			t.Errorf("%q %d: expected %q, got %q", tc.path, tc.pos, tc.expected, text)
+		}
+	}
+}
+
+func TestCommentsSetText(t *testing.T) {
+	var node *Node
+	if err := Unmarshal([]byte(`{a: 1, s: "x", b: {c: [1, 2], e: {}, f: []}}`), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path  string
+		pos   CommentPos
+		text  string
+		style CommentStyle
+	}{
+		{"", CommentBefore, "Config\nfile", CommentHash},
+		{"", CommentInsideLast, "end of root", CommentSlash},
+		{"/a", CommentKey, "key", CommentBlock},
+		{"/a", CommentAfter, "after\na", CommentHash},
+		{"/s", CommentKey, "key s", CommentHash},
+		{"/b", CommentBefore, "multi\nline", CommentBlock},
+		{"/b", CommentInsideFirst, "first", CommentHash},
+		{"/b", CommentInsideLast, "end of b\n\nreally", CommentHash},
+		{"/b/c", CommentBefore, "list", CommentSlash},
+		{"/b/c", CommentInsideLast, "end", CommentBlock},
+		{"/b/c/1", CommentBefore, "second", CommentHash},
+		{"/b/e", CommentInsideLast, "empty", CommentHash},
+		{"/b/f", CommentInsideLast, "empty", CommentBlock},
+	}
+	for _, tc := range testCases {
+		elem, err := node.Get(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		elem.Cm.SetText(tc.pos, tc.text, tc.style)
+	}
+
+	out, err := Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# Config
+# file
+{
+  a: /* key */ 1 # after a
+  s: # key s
+    x
+  /*
+  multi
+  line
+  */
+  b: { # first
+    // list
+    c: [
+      1
+      # second
+      2
+      /* end */
+    ]
+    e: {
+      # empty
+    }
+    f: [
+      /* empty */
+    ]
+    # end of b
+    #
+    # really
+  }
+  // end of root
+}`
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, out)
+	}
+
+	var node2 *Node
+	if err := Unmarshal(out, &node2); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		elem, err := node2.Get(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := tc.text
+		if tc.pos == CommentAfter {
+			expected = "after a"
+		}
+		if text := elem.Cm.Text(tc.pos); text != expected {
+			t.Errorf("%q %d: expected %q, got %q", tc.path, tc.pos, expected, text)
+		}
+	}
+
+	// Comments in the format used by Unmarshal() are written as is, and empty
+	// text removes the comments.
+	b := node.NK("b")
+	b.NK("c").Cm.Before = "    # raw\n    "
+	b.Cm.SetText(CommentInsideFirst, "", CommentHash)
+	b.Cm.SetText(CommentInsideLast, "", CommentHash)
+	out, err = Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `# Config
+# file
+{
+  a: /* key */ 1 # after a
+  s: # key s
+    x
+  /*
+  multi
+  line
+  */
+  b: {
+    # raw
+    c: [
+      1
+      # second
+      2
+      /* end */
+    ]
+    e: {
+      # empty
+    }
+    f: [
+      /* empty */
+    ]
+  }
+  // end of root
+}`
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, out)
+	}
+}
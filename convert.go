@@ -0,0 +1,221 @@
+package hjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// NodeFrom returns a Node tree for v, like the tree that Unmarshal() would
+// create for the output of Marshal(v), but without encoding v as text. Struct
+// fields are handled as by Marshal(), including the `comment` tag which is
+// stored in the Comments of the Node for that field. The hjson.Marshaler,
+// json.Marshaler and encoding.TextMarshaler interfaces are used in the same
+// way as by Marshal(). Any *Node found in v is copied, including its comments
+// and position.
+//
+// Integers are stored as json.Number to keep their exact value, other numbers
+// are stored as float64.
+func NodeFrom(v interface{}) (*Node, error) {
+	b := nodeBuilder{
+		valueWalker: valueWalker{
+			structTypeCache: map[reflect.Type][]structFieldInfo{},
+		},
+	}
+	return b.node(reflect.ValueOf(v))
+}
+
+// nodeBuilder creates Node trees from Go values, walking through the values in
+// the same way as hjsonEncoder.
+type nodeBuilder struct {
+	valueWalker
+}
+
+// node returns a new Node for value.
+func (b *nodeBuilder) node(value reflect.Value) (*Node, error) {
+	value, err := marshalHjson(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var res Node
+	if value.IsValid() {
+		if node, ok := value.Interface().(Node); ok {
+			res = node
+		} else if pNode, ok := value.Interface().(*Node); ok && pNode != nil {
+			res = *pNode
+		} else {
+			res.Value = value.Interface()
+		}
+		if res.Pos != nil {
+			pos := *res.Pos
+			res.Pos = &pos
+		}
+	}
+
+	if res.Value, err = b.value(reflect.ValueOf(res.Value)); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// value returns value converted to the types found in a Node tree.
+func (b *nodeBuilder) value(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+
+	kind := value.Kind()
+
+	if err := b.enter(value); err != nil {
+		return nil, err
+	}
+	defer b.leave(value)
+
+	if kind == reflect.Interface || kind == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		node, err := b.node(value.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return node.Value, nil
+	}
+
+	if value.Type() == rawMessageType {
+		if value.IsNil() {
+			return nil, nil
+		}
+		// Only comments are kept from the raw text. Its whitespace is replaced
+		// by the indentation of the output.
+		options := DefaultDecoderOptions()
+		options.WhitespaceAsComments = false
+		var node Node
+		if err := UnmarshalWithOptions(value.Bytes(), &node, options); err != nil {
+			return nil, err
+		}
+		return node.Value, nil
+	}
+
+	if om, ok := value.Interface().(OrderedMap); ok {
+		return b.object(orderedMapFields(om))
+	}
+
+	if mv, ok, err := marshalValue(value); ok {
+		if err != nil {
+			return nil, err
+		}
+		return b.value(mv)
+	}
+
+	switch kind {
+	case reflect.String:
+		if value.Type() == JSONNumberType {
+			n := value.String()
+			if n == "" {
+				n = "0"
+			}
+			return json.Number(n), nil
+		}
+		return value.String(), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(value.Int(), 10)), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		return json.Number(strconv.FormatUint(value.Uint(), 10)), nil
+
+	case reflect.Float32, reflect.Float64:
+		// JSON numbers must be finite. Store non-finite numbers as null.
+		number := value.Float()
+		if math.IsInf(number, 0) || math.IsNaN(number) {
+			return nil, nil
+		}
+		return number, nil
+
+	case reflect.Bool:
+		return value.Bool(), nil
+
+	case reflect.Slice, reflect.Array:
+		arr := make([]interface{}, value.Len())
+		for i := range arr {
+			elem, err := b.node(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = elem
+		}
+		return arr, nil
+
+	case reflect.Map:
+		fis, err := mapFields(value)
+		if err != nil {
+			return nil, err
+		}
+		return b.object(fis)
+
+	case reflect.Struct:
+		return b.object(b.structFields(value))
+	}
+
+	return nil, errors.New("Unsupported type " + value.Type().String())
+}
+
+// object returns an OrderedMap containing a Node for each of fis. The comment
+// tags of struct fields are stored as comments set by Comments.SetText(), so
+// that they are indented by Marshal().
+func (b *nodeBuilder) object(fis []fieldInfo) (*OrderedMap, error) {
+	res := NewOrderedMap()
+	// Marshal() writes an empty line after each field with a comment tag.
+	emptyLine := false
+	for _, fi := range fis {
+		elem, err := b.node(fi.field)
+		if err != nil {
+			return nil, err
+		}
+		if fi.comment != "" && elem.Cm.Before == "" {
+			elem.Cm.SetText(CommentBefore, fi.comment, CommentHash)
+		}
+		if emptyLine {
+			if elem.Cm.Before == "" {
+				elem.Cm.text |= 1 << uint(CommentBefore)
+			}
+			elem.Cm.Before = "\n" + elem.Cm.Before
+		}
+		emptyLine = fi.comment != ""
+		res.Set(fi.name, elem)
+	}
+	return res, nil
+}
+
+// Decode stores the value of the Node tree c in the value pointed to by v,
+// using the same rules as UnmarshalWithOptions(), but without parsing any
+// text. If v is a *Node, it is set to a copy of c. The comments in c are
+// available to any hjson.Unmarshaler found in v.
+func (c *Node) Decode(v interface{}, options DecoderOptions) error {
+	if c == nil {
+		return fmt.Errorf("Node is nil")
+	}
+
+	// Convert any Go values that have been stored in the tree to the types
+	// that are supported by the assign functions.
+	node, err := NodeFrom(c)
+	if err != nil {
+		return err
+	}
+
+	p := newHjsonParser(nil, options)
+	return p.unmarshal(v, func(rv reflect.Value) (interface{}, error) {
+		if !p.willAssign && !p.nodeDestination {
+			// The destination is an *OrderedMap.
+			return unwrapNodes(node), nil
+		}
+		return node, nil
+	})
+}
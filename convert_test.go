@@ -0,0 +1,183 @@
+package hjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type convertServer struct {
+	Host string `json:"host" comment:"Host name"`
+	Port int    `json:"port,omitempty"`
+}
+
+type convertConfig struct {
+	Name    string            `json:"name" comment:"The name\nof the service"`
+	Servers []convertServer   `json:"servers"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Timeout time.Duration     `json:"timeout"`
+	Started time.Time         `json:"started"`
+	Port    hjsonCommented    `json:"port"`
+	Extra   *Node             `json:"extra"`
+}
+
+func TestNodeFrom(t *testing.T) {
+	config := convertConfig{
+		Name: "app",
+		Servers: []convertServer{
+			{Host: "a", Port: 80},
+			{Host: "b"},
+		},
+		Timeout: 5,
+		Started: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Port:    hjsonCommented{Value: 8080, Comment: "listen port"},
+		Extra:   &Node{Value: []interface{}{1, 2.5}, Cm: Comments{After: " # extra"}},
+	}
+
+	node, err := NodeFrom(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.NK("name").Value != "app" || node.NK("servers").NI(0).NK("port").Value != json.Number("80") ||
+		node.NK("servers").NI(1).NK("port") != nil || node.NK("labels") != nil ||
+		node.NK("timeout").Value != json.Number("5") ||
+		node.NK("started").Value != "2020-01-02T03:04:05Z" ||
+		node.NK("extra").NI(1).Value != 2.5 {
+		t.Errorf("Unexpected node tree: %#v", node)
+	}
+
+	// The comments from the struct tags are indented using the options and the
+	// depth where the Node is written.
+	tabs := DefaultOptions()
+	tabs.IndentBy = "\t"
+	tabs.BaseIndentation = "> "
+	for _, options := range []EncoderOptions{DefaultOptions(), tabs} {
+		expected, err := MarshalWithOptions(config, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := MarshalWithOptions(node, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(expected) {
+			t.Errorf("Expected:\n%s\n\nGot:\n%s", string(expected), string(b))
+		}
+		b, err = MarshalWithOptions(map[string]interface{}{"nested": []interface{}{node}}, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "\n> \t\t\t# The name\n") && options.IndentBy == "\t" {
+			t.Errorf("Unexpected indentation of nested comments:\n%s", string(b))
+		}
+	}
+	if node.NK("name").Cm.Text(CommentBefore) != "The name\nof the service" {
+		t.Errorf("Unexpected comment: %q", node.NK("name").Cm.Before)
+	}
+
+	// The empty line after a field with a comment tag is not kept when sorting
+	// makes the field first.
+	node.SortKeys(false, func(a, b string) bool { return a == "servers" })
+	b, err := Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "{\n  servers: [") {
+		t.Errorf("Unexpected output after sorting:\n%s", string(b))
+	}
+
+	// Nodes are copied.
+	config.Extra.Value = nil
+	if node.NK("extra").Len() != 2 {
+		t.Error("Expected a copy of the Node")
+	}
+
+	type cycle struct {
+		Next *cycle
+	}
+	c := &cycle{}
+	c.Next = c
+	if _, err = NodeFrom(c); err == nil {
+		t.Error("Expected an error for a circular reference")
+	}
+	if _, err = NodeFrom(make(chan int)); err == nil {
+		t.Error("Expected an error for an unsupported type")
+	}
+}
+
+func TestNodeDecode(t *testing.T) {
+	var node Node
+	err := Unmarshal([]byte(`{
+  name: app
+  servers: [
+    {host: "a", port: 80}
+  ]
+  timeout: 5
+  started: 2020-01-02T03:04:05Z
+  port: 8080 # listen port
+  extra: [1, 2.5]
+}`), &node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values of any type can be stored in the tree before decoding.
+	if _, _, err = node.NK("servers").NI(0).SetKey("port", uint16(81)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = node.SetKey("labels", map[string]interface{}{"env": "prod"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var config convertConfig
+	if err = node.Decode(&config, DefaultDecoderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	expected := convertConfig{
+		Name:    "app",
+		Servers: []convertServer{{Host: "a", Port: 81}},
+		Labels:  map[string]string{"env": "prod"},
+		Timeout: 5,
+		Started: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Port:    hjsonCommented{Value: 8080, Comment: "listen port"},
+		Extra:   config.Extra,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected:\n%#v\n\nGot:\n%#v", expected, config)
+	}
+	if config.Extra == nil || config.Extra.NI(1).Value != 2.5 {
+		t.Errorf("Unexpected extra: %#v", config.Extra)
+	}
+
+	var value interface{}
+	if err = node.NK("extra").Decode(&value, DecoderOptions{UseJSONNumber: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value, []interface{}{json.Number("1"), json.Number("2.5")}) {
+		t.Errorf("Unexpected value: %#v", value)
+	}
+
+	var om OrderedMap
+	if err = node.NK("servers").NI(0).Decode(&om, DefaultDecoderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(om.Keys, []string{"host", "port"}) || om.Map["port"] != json.Number("81") {
+		t.Errorf("Unexpected OrderedMap: %#v", om)
+	}
+
+	var nodeCopy Node
+	if err = node.Decode(&nodeCopy, DefaultDecoderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if nodeCopy.NK("port").Cm != node.NK("port").Cm || nodeCopy.NK("port") == node.NK("port") {
+		t.Error("Expected a copy of the Node tree")
+	}
+
+	var port int
+	if err = node.NK("name").Decode(&port, DefaultDecoderOptions()); err == nil {
+		t.Error("Expected a type error")
+	}
+}
@@ -3,9 +3,8 @@ package hjson
 import (
 	"bytes"
 	"encoding"
-	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 )
@@ -32,6 +31,17 @@ type ElemTyper interface {
 	ElemType() reflect.Type
 }
 
+// Unmarshaler is the interface implemented by types that can unmarshal an
+// hjson.Node tree of themselves, for example to read comments from the input.
+// Unmarshaler has precedence over json.Unmarshaler and
+// encoding.TextUnmarshaler.
+type Unmarshaler interface {
+	// UnmarshalHjson is called with the Node tree parsed from the input for
+	// the receiver. All elements in the tree are of type *hjson.Node, see the
+	// documentation for hjson.Node.
+	UnmarshalHjson(node *Node) error
+}
+
 // DecoderOptions defines options for decoding Hjson.
 type DecoderOptions struct {
 	// UseJSONNumber causes the Decoder to unmarshal a number into an interface{} as a
@@ -53,8 +63,53 @@ type DecoderOptions struct {
 	// WhitespaceAsComments instead is set to false, only actual comments are
 	// stored as comments in Node structs.
 	WhitespaceAsComments bool
+	// RecoverFromErrors causes the parser to continue after syntax errors in
+	// objects and arrays, by skipping ahead to the next line or punctuator.
+	// All errors found are returned in an ErrorList, and the destination will
+	// contain the values that could be parsed. If RecoverFromErrors is set to
+	// false, parsing stops at the first error.
+	RecoverFromErrors bool
+	// RecordPositions only has any effect when an hjson.Node struct (or an
+	// *hjson.Node pointer) is used as target for Unmarshal. If RecordPositions
+	// is set to true, the position in the input of every value (and of its key,
+	// if the value is an element in an object) is stored in Node.Pos.
+	RecordPositions bool
+	// MaxDepth is the maximum nesting depth of objects and arrays in the input.
+	// If MaxDepth is 0, the limit DefaultMaxDepth is used, to protect against
+	// stack exhaustion. Use a negative value to disable the limit.
+	MaxDepth int
+	// MaxInputBytes is the maximum size of the input in bytes. For a Decoder the
+	// limit applies to the data buffered for each call to Decode(). If
+	// MaxInputBytes is 0, the limit DefaultMaxInputBytes is used. Use a negative
+	// value to disable the limit.
+	MaxInputBytes int
+	// MaxStringLength is the maximum length in bytes of any string or key in the
+	// input (after unescaping). If MaxStringLength is 0, the limit
+	// DefaultMaxStringLength is used. Use a negative value to disable the limit.
+	MaxStringLength int
+	// MaxElements is the maximum total number of values in the input, counting
+	// all array elements and object members on all levels. If MaxElements is 0,
+	// the limit DefaultMaxElements is used. Use a negative value to disable the
+	// limit.
+	MaxElements int
 }
 
+// DefaultMaxDepth is the maximum nesting depth used if DecoderOptions.MaxDepth
+// is 0.
+const DefaultMaxDepth = 10000
+
+// DefaultMaxInputBytes is the maximum input size used if
+// DecoderOptions.MaxInputBytes is 0.
+const DefaultMaxInputBytes = 256 << 20
+
+// DefaultMaxStringLength is the maximum string length used if
+// DecoderOptions.MaxStringLength is 0.
+const DefaultMaxStringLength = 64 << 20
+
+// DefaultMaxElements is the maximum number of values used if
+// DecoderOptions.MaxElements is 0.
+const DefaultMaxElements = 10000000
+
 // DefaultDecoderOptions returns the default decoding options.
 func DefaultDecoderOptions() DecoderOptions {
 	return DecoderOptions{
@@ -62,21 +117,104 @@ func DefaultDecoderOptions() DecoderOptions {
 		DisallowUnknownFields: false,
 		DisallowDuplicateKeys: false,
 		WhitespaceAsComments:  true,
+		RecoverFromErrors:     false,
+		RecordPositions:       false,
+		MaxDepth:              DefaultMaxDepth,
+		MaxInputBytes:         DefaultMaxInputBytes,
+		MaxStringLength:       DefaultMaxStringLength,
+		MaxElements:           DefaultMaxElements,
 	}
 }
 
 type hjsonParser struct {
 	DecoderOptions
-	data              []byte
-	at                int  // The index of the current character
-	ch                byte // The current character
-	structTypeCache   map[reflect.Type]structFieldMap
-	willMarshalToJSON bool
-	nodeDestination   bool
+	data            []byte
+	at              int  // The index of the current character
+	ch              byte // The current character
+	structTypeCache map[reflect.Type]structFieldMap
+	defaultsCache   map[reflect.Type][]structDefault
+	willAssign      bool // If the parsed values will be stored in Go values by assign().
+	nodeDestination bool
+	rd              io.Reader // If not nil, more data is read from rd when needed.
+	rdErr           error     // The first error returned by rd.
+	errs            ErrorList // Errors that have been recovered from.
+	assignErr       error     // The first error found by assign().
+	assignCtx       assignContext
+	posCache        lineCache // Used by position().
+	depth           int       // Current nesting depth of objects and arrays.
+	elements        int       // Number of values read so far.
+	limitErr        error     // Error for an exceeded limit, cannot be recovered from.
+	// The limits from DecoderOptions, with the defaults applied. 0 means no
+	// limit.
+	maxDepth, maxInputBytes, maxStringLength, maxElements int
+	// The zero-based offset, line and column in the stream of the first byte in
+	// data, when reading from a Decoder.
+	baseOffset, baseLine, baseColumn int
+	// If not nil, the offsets in data of the start and the end of each value
+	// read into a Node. Used by assign() to store the Node tree in Go values as
+	// if the Go values had been the destination when parsing.
+	nodeSpans map[*Node][2]int
+	// If true, a single value without braces or brackets ends where the next
+	// value in the stream begins, instead of at the end of the input.
+	stream bool
+}
+
+// lineCache contains the result of the latest call to hjsonParser.position().
+type lineCache struct {
+	offset    int
+	line      int
+	lineStart int
+}
+
+// Minimum number of bytes to request from hjsonParser.rd in each call to Read().
+const minReadSize = 4096
+
+func newHjsonParser(data []byte, options DecoderOptions) *hjsonParser {
+	return &hjsonParser{
+		DecoderOptions:  options,
+		data:            data,
+		at:              0,
+		ch:              ' ',
+		structTypeCache: map[reflect.Type]structFieldMap{},
+		defaultsCache:   map[reflect.Type][]structDefault{},
+		maxDepth:        limitOption(options.MaxDepth, DefaultMaxDepth),
+		maxInputBytes:   limitOption(options.MaxInputBytes, DefaultMaxInputBytes),
+		maxStringLength: limitOption(options.MaxStringLength, DefaultMaxStringLength),
+		maxElements:     limitOption(options.MaxElements, DefaultMaxElements),
+	}
+}
+
+// limitOption returns the limit to use for a limit option with the value
+// value, where 0 means the default limit def and a negative value means no
+// limit. Returns 0 for no limit.
+func limitOption(value, def int) int {
+	if value == 0 {
+		return def
+	}
+	if value < 0 {
+		return 0
+	}
+	return value
 }
 
 var unmarshalerText = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 var elemTyper = reflect.TypeOf((*ElemTyper)(nil)).Elem()
+var unmarshalerHjson = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// isUnmarshalerHjson returns true if t, or a pointer to t, implements
+// hjson.Unmarshaler. t must not have been unraveled.
+func isUnmarshalerHjson(t reflect.Type) bool {
+	for a := 0; a < maxPointerDepth && t != nil; a++ {
+		if t.Implements(unmarshalerHjson) || reflect.PtrTo(t).Implements(unmarshalerHjson) {
+			return true
+		}
+		if t.Kind() != reflect.Ptr {
+			break
+		}
+		t = t.Elem()
+	}
+	return false
+}
 
 func (p *hjsonParser) setComment1(pCm *string, ci commentInfo) {
 	if ci.hasComment {
@@ -95,6 +233,8 @@ func (p *hjsonParser) setComment2(pCm *string, ciA, ciB commentInfo) {
 
 func (p *hjsonParser) resetAt() {
 	p.at = 0
+	p.depth = 0
+	p.elements = 0
 	p.next()
 }
 
@@ -102,31 +242,305 @@ func isPunctuatorChar(c byte) bool {
 	return c == '{' || c == '}' || c == '[' || c == ']' || c == ',' || c == ':'
 }
 
-func (p *hjsonParser) errAt(message string) error {
-	if p.at <= len(p.data) {
-		var i int
-		col := 0
-		line := 1
-		for i = p.at - 1; i > 0 && p.data[i] != '\n'; i-- {
-			col++
-		}
-		for ; i > 0; i-- {
-			if p.data[i] == '\n' {
-				line++
+// SyntaxError is returned by Unmarshal(), UnmarshalWithOptions() and
+// Decoder.Decode() when the input could not be parsed. Error() returns the
+// same text as the errors returned before SyntaxError was added, so the
+// position in the text can differ from the fields on the first line.
+type SyntaxError struct {
+	// Msg describes the error.
+	Msg string
+	// Offset is the byte offset in the input where the error was found.
+	Offset int
+	// Line is the 1-based line number where the error was found.
+	Line int
+	// Column is the 1-based byte position within Line where the error was
+	// found.
+	Column int
+	// Excerpt contains the beginning of Line. Empty if the error was found at
+	// the end of the input.
+	Excerpt string
+
+	// text is the error message in the format used before SyntaxError was
+	// added, with its own position and excerpt.
+	text string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.text != "" {
+		return e.text
+	}
+	if e.Excerpt == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s at line %d,%d >>> %s", e.Msg, e.Line, e.Column, e.Excerpt)
+}
+
+// ErrorList is returned instead of a single error by Unmarshal(),
+// UnmarshalWithOptions() and Decoder.Decode() if DecoderOptions.RecoverFromErrors
+// is true and one or more errors were found.
+type ErrorList []*SyntaxError
+
+func (e ErrorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// recordError returns err unless p.RecoverFromErrors is true. In that case err
+// is stored in p.errs and nil is returned.
+func (p *hjsonParser) recordError(err error) error {
+	if err == nil || !p.RecoverFromErrors || err == p.limitErr {
+		return err
+	}
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		syntaxErr = p.errAt(err.Error()).(*SyntaxError)
+	}
+	// The same error can be found on several levels, for example end of input.
+	if len(p.errs) == 0 || p.errs[len(p.errs)-1].Offset != syntaxErr.Offset {
+		p.errs = append(p.errs, syntaxErr)
+	}
+	return nil
+}
+
+// recoverFrom returns err unless p.RecoverFromErrors is true. In that case err
+// is stored in p.errs and the parser skips ahead to the next line, or to
+// the character after the next comma, or to closer (which is the character
+// that would end the current object or array) if found before any of the
+// others. Any nested objects or arrays are skipped.
+func (p *hjsonParser) recoverFrom(err error, closer byte) error {
+	if err = p.recordError(err); err != nil {
+		return err
+	}
+
+	depth := 0
+	for p.ch > 0 {
+		switch p.ch {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth > 0 {
+				depth--
+			} else if p.ch == closer {
+				return nil
 			}
+		case ',', '\n':
+			if depth == 0 {
+				p.next()
+				return nil
+			}
+		}
+		p.next()
+	}
+	return nil
+}
+
+// recoveredErrors returns p.errs, or nil if no errors have been recovered from.
+func (p *hjsonParser) recoveredErrors() error {
+	if len(p.errs) > 0 {
+		return p.errs
+	}
+	return nil
+}
+
+// position returns the 1-based line and column for the specified offset in
+// p.data, and the offset of the first character on that line.
+func (p *hjsonParser) position(offset int) (line, col, lineStart int) {
+	i := 0
+	line = 1
+	// Positions are mostly requested in increasing order, so continue from the
+	// previous call if possible.
+	if p.posCache.line > 0 && offset >= p.posCache.offset {
+		i, line, lineStart = p.posCache.offset, p.posCache.line, p.posCache.lineStart
+	}
+	for ; i < offset; i++ {
+		if p.data[i] == '\n' {
+			line++
+			lineStart = i + 1
 		}
-		samEnd := p.at - col + 20
+	}
+	p.posCache.offset, p.posCache.line, p.posCache.lineStart = offset, line, lineStart
+	return line, offset - lineStart + 1, lineStart
+}
+
+// positionAt returns the Position in the whole input for the specified offset
+// in p.data.
+func (p *hjsonParser) positionAt(offset int) Position {
+	line, col, _ := p.position(offset)
+	return p.streamPosition(offset, line, col)
+}
+
+// valueEnd returns the offset in p.data of the current position, excluding
+// any trailing whitespace after the offset start.
+func (p *hjsonParser) valueEnd(start int) int {
+	end := p.at - 1
+	if end > len(p.data) {
+		end = len(p.data)
+	}
+	for end > start && p.data[end-1] <= ' ' {
+		end--
+	}
+	return end
+}
+
+// span returns the Span from the offset start to the current position,
+// excluding any trailing whitespace.
+func (p *hjsonParser) span(start int) Span {
+	return Span{Start: p.positionAt(start), End: p.positionAt(p.valueEnd(start))}
+}
+
+// setPos stores the position of the value in Node.Pos, if value is a Node and
+// p.RecordPositions is true. start is the offset of the first character of the
+// value.
+func (p *hjsonParser) setPos(value interface{}, start int) {
+	node, ok := value.(*Node)
+	if !ok {
+		return
+	}
+	if p.RecordPositions {
+		node.Pos = &NodePos{Value: p.span(start)}
+	}
+	if p.nodeSpans != nil {
+		p.nodeSpans[node] = [2]int{start, p.valueEnd(start)}
+	}
+}
+
+// streamPosition returns the Position in the whole input (which is the whole
+// stream for a Decoder) for the offset in p.data and its 1-based line and
+// column in p.data.
+func (p *hjsonParser) streamPosition(offset, line, col int) Position {
+	if line == 1 {
+		col += p.baseColumn
+	}
+	return Position{Offset: p.baseOffset + offset, Line: p.baseLine + line, Column: col}
+}
+
+func (p *hjsonParser) errAt(message string) error {
+	offset := p.at - 1
+	if offset > len(p.data) {
+		offset = len(p.data)
+	}
+	line, col, lineStart := p.position(offset)
+	pos := p.streamPosition(offset, line, col)
+	err := &SyntaxError{
+		Msg:    message,
+		Offset: pos.Offset,
+		Line:   pos.Line,
+		Column: pos.Column,
+	}
+	if offset < len(p.data) {
+		samEnd := lineStart + 20
 		if samEnd > len(p.data) {
 			samEnd = len(p.data)
 		}
-		return fmt.Errorf("%s at line %d,%d >>> %s", message, line, col, string(p.data[p.at-col:samEnd]))
+		err.Excerpt = string(p.data[lineStart:samEnd])
+		err.text = p.errText(message, offset, line, col, lineStart)
+	}
+	return err
+}
+
+// errText returns message followed by the position of offset, which has the
+// line, column and start of line returned by p.position(), in the format that
+// errors had before SyntaxError was added. That format does not count a line
+// feed at offset 0, puts a line feed at offset in column 0 of the next line,
+// and on the first line the column is 0-based and the excerpt starts at
+// offset 1.
+func (p *hjsonParser) errText(message string, offset, line, col, lineStart int) string {
+	if offset > 0 && p.data[offset] == '\n' {
+		line, col, lineStart = line+1, 0, offset+1
+	}
+	start := lineStart
+	if lineStart < 2 {
+		line, col, start = 1, offset, 1
+	} else if p.data[0] == '\n' {
+		line--
+	}
+	pos := p.streamPosition(offset, line, col)
+	end := start + 20
+	if end > len(p.data) {
+		end = len(p.data)
+	}
+	return fmt.Sprintf("%s at line %d,%d >>> %s", message, pos.Line, pos.Column, string(p.data[start:end]))
+}
+
+// errLimit returns a SyntaxError for an exceeded limit. The error is fatal
+// even if p.RecoverFromErrors is true.
+func (p *hjsonParser) errLimit(message string) error {
+	p.limitErr = p.errAt(message)
+	return p.limitErr
+}
+
+func (p *hjsonParser) errInputSize() error {
+	return fmt.Errorf("Input exceeds the maximum size of %d bytes (DecoderOptions.MaxInputBytes)",
+		p.maxInputBytes)
+}
+
+// enter is called when starting to read an object or an array. Returns an
+// error if the maximum nesting depth has been exceeded. Call p.depth-- when
+// done reading the object or array.
+func (p *hjsonParser) enter() error {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return p.errLimit(fmt.Sprintf("Exceeded the maximum nesting depth of %d (DecoderOptions.MaxDepth)",
+			p.maxDepth))
+	}
+	return nil
+}
+
+// checkStringLength returns s, or an error if s is longer than
+// p.maxStringLength.
+func (p *hjsonParser) checkStringLength(s string) (string, error) {
+	if p.maxStringLength > 0 && len(s) > p.maxStringLength {
+		return "", p.errLimit(fmt.Sprintf("String exceeds the maximum length of %d bytes (DecoderOptions.MaxStringLength)",
+			p.maxStringLength))
+	}
+	return s, nil
+}
+
+// fill appends more data from p.rd to p.data. Returns false if no more data
+// could be read.
+func (p *hjsonParser) fill() bool {
+	if p.rd == nil || p.rdErr != nil {
+		return false
+	}
+	for {
+		if cap(p.data)-len(p.data) < minReadSize {
+			newData := make([]byte, len(p.data), 2*cap(p.data)+minReadSize)
+			copy(newData, p.data)
+			p.data = newData
+		}
+		buf := p.data[len(p.data):cap(p.data)]
+		if p.maxInputBytes > 0 {
+			rest := p.maxInputBytes - len(p.data)
+			if rest < 1 {
+				// Read a single byte to find out if there is any more input.
+				rest = 1
+			}
+			if len(buf) > rest {
+				buf = buf[:rest]
+			}
+		}
+		n, err := p.rd.Read(buf)
+		if p.maxInputBytes > 0 && len(p.data)+n > p.maxInputBytes {
+			p.rdErr = p.errInputSize()
+			return false
+		}
+		p.data = p.data[:len(p.data)+n]
+		if err != nil {
+			p.rdErr = err
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
 	}
-	return errors.New(message)
 }
 
 func (p *hjsonParser) next() bool {
 	// get the next character.
-	if p.at < len(p.data) {
+	if p.at < len(p.data) || p.fill() {
 		p.ch = p.data[p.at]
 		p.at++
 		return true
@@ -149,6 +563,8 @@ func (p *hjsonParser) prev() bool {
 
 func (p *hjsonParser) peek(offs int) byte {
 	pos := p.at + offs
+	for pos >= len(p.data) && p.fill() {
+	}
 	if pos >= 0 && pos < len(p.data) {
 		return p.data[p.at+offs]
 	}
@@ -201,9 +617,13 @@ func (p *hjsonParser) readString(allowML bool) (string, error) {
 			if allowML && exitCh == '\'' && p.ch == '\'' && res.Len() == 0 {
 				// ''' indicates a multiline string
 				p.next()
-				return p.readMLString()
+				s, err := p.readMLString()
+				if err != nil {
+					return "", err
+				}
+				return p.checkStringLength(s)
 			} else {
-				return res.String(), nil
+				return p.checkStringLength(res.String())
 			}
 		}
 		if p.ch == '\\' {
@@ -330,7 +750,7 @@ func (p *hjsonParser) readKeyname() (string, error) {
 				p.at = start + space
 				return "", p.errAt("Found whitespace in your key name (use quotes to include)")
 			}
-			return name.String(), nil
+			return p.checkStringLength(name.String())
 		} else if p.ch <= ' ' {
 			if p.ch == 0 {
 				return "", p.errAt("Found EOF while looking for a key name (check your syntax)")
@@ -489,11 +909,12 @@ func (p *hjsonParser) readTfnns(dest reflect.Value, t reflect.Type) (interface{}
 					}
 				default:
 					if chf == '-' || chf >= '0' && chf <= '9' {
-						// Always use json.Number if we will marshal to JSON.
+						// Always use json.Number if the value will be assigned by assign(),
+						// to keep the exact number text.
 						if n, err := tryParseNumber(
 							value.Bytes(),
 							false,
-							p.willMarshalToJSON || p.DecoderOptions.UseJSONNumber,
+							p.willAssign || p.DecoderOptions.UseJSONNumber,
 						); err == nil {
 							return p.maybeWrapNode(&node, n)
 						}
@@ -503,7 +924,11 @@ func (p *hjsonParser) readTfnns(dest reflect.Value, t reflect.Type) (interface{}
 
 			if isEol {
 				// remove any whitespace at the end (ignored in quoteless strings)
-				return p.maybeWrapNode(&node, strings.TrimSpace(value.String()))
+				s, err := p.checkStringLength(strings.TrimSpace(value.String()))
+				if err != nil {
+					return nil, err
+				}
+				return p.maybeWrapNode(&node, s)
 			}
 		}
 		value.WriteByte(p.ch)
@@ -548,6 +973,11 @@ func getElemTyperType(rv reflect.Value, t reflect.Type) reflect.Type {
 }
 
 func (p *hjsonParser) readArray(dest reflect.Value, t reflect.Type) (value interface{}, err error) {
+	if err = p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
 	var node Node
 	array := make([]interface{}, 0, 1)
 
@@ -580,7 +1010,16 @@ func (p *hjsonParser) readArray(dest reflect.Value, t reflect.Type) (value inter
 		var elemNode *Node
 		var val interface{}
 		if val, err = p.readValue(reflect.Value{}, elemType); err != nil {
-			return nil, err
+			if err = p.recoverFrom(err, ']'); err != nil {
+				return nil, err
+			}
+			ciBefore = p.white()
+			if p.ch == ']' {
+				p.setComment1(&node.Cm.InsideLast, ciBefore)
+				p.next()
+				return p.maybeWrapNode(&node, array)
+			}
+			continue
 		}
 		if p.nodeDestination {
 			var ok bool
@@ -612,7 +1051,12 @@ func (p *hjsonParser) readArray(dest reflect.Value, t reflect.Type) (value inter
 		ciBefore = ciAfter
 	}
 
-	return nil, p.errAt("End of input while parsing an array (did you forget a closing ']'?)")
+	err = p.errAt("End of input while parsing an array (did you forget a closing ']'?)")
+	if err = p.recoverFrom(err, ']'); err != nil {
+		return nil, err
+	}
+	p.setComment1(&node.Cm.InsideLast, ciBefore)
+	return p.maybeWrapNode(&node, array)
 }
 
 func (p *hjsonParser) readObject(
@@ -621,6 +1065,11 @@ func (p *hjsonParser) readObject(
 	t reflect.Type,
 	ciBefore commentInfo,
 ) (value interface{}, err error) {
+	if err = p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
 	// Parse an object value.
 	var node Node
 	var elemNode *Node
@@ -673,14 +1122,51 @@ func (p *hjsonParser) readObject(
 		}
 	}
 
+	closer := byte('}')
+	if withoutBraces {
+		closer = 0
+	}
+
+	// skipMember is called instead of returning err. Returns true if the
+	// object has been closed.
+	skipMember := func(e error) (bool, error) {
+		if err := p.recoverFrom(e, closer); err != nil {
+			return false, err
+		}
+		ciBefore = p.white()
+		if p.ch == '}' && !withoutBraces {
+			p.setComment1(&node.Cm.InsideLast, ciBefore)
+			p.next()
+			return true, nil
+		}
+		return false, nil
+	}
+
 	for p.ch > 0 {
 		var key string
+		keyStart := p.at - 1
 		if key, err = p.readKeyname(); err != nil {
-			return nil, err
+			closed, err := skipMember(err)
+			if err != nil {
+				return nil, err
+			} else if closed {
+				return p.maybeWrapNode(&node, object)
+			}
+			continue
+		}
+		var keySpan Span
+		if p.RecordPositions {
+			keySpan = p.span(keyStart)
 		}
 		ciKey := p.white()
 		if p.ch != ':' {
-			return nil, p.errAt("Expected ':' instead of '" + string(p.ch) + "'")
+			closed, err := skipMember(p.errAt("Expected ':' instead of '" + string(p.ch) + "'"))
+			if err != nil {
+				return nil, err
+			} else if closed {
+				return p.maybeWrapNode(&node, object)
+			}
+			continue
 		}
 		p.next()
 
@@ -718,7 +1204,13 @@ func (p *hjsonParser) readObject(
 		// duplicate keys overwrite the previous value
 		var val interface{}
 		if val, err = p.readValue(newDest, elemType); err != nil {
-			return nil, err
+			closed, err := skipMember(err)
+			if err != nil {
+				return nil, err
+			} else if closed {
+				return p.maybeWrapNode(&node, object)
+			}
+			continue
 		}
 		if p.nodeDestination {
 			var ok bool
@@ -727,6 +1219,9 @@ func (p *hjsonParser) readObject(
 				elemNode.Cm.Key += elemNode.Cm.Before
 				elemNode.Cm.Before = ""
 				p.setComment1(&elemNode.Cm.Before, ciBefore)
+				if elemNode.Pos != nil {
+					elemNode.Pos.Key = keySpan
+				}
 			}
 		}
 		// Check white before comma because comma might be on other line.
@@ -746,16 +1241,22 @@ func (p *hjsonParser) readObject(
 			p.setComment1(&node.Cm.InsideLast, ciAfter)
 			oldValue, isDuplicate := object.Set(key, val)
 			if isDuplicate && p.DisallowDuplicateKeys {
-				return nil, p.errAt(fmt.Sprintf("Found duplicate values ('%#v' and '%#v') for key '%v'",
+				err = p.errAt(fmt.Sprintf("Found duplicate values ('%#v' and '%#v') for key '%v'",
 					oldValue, val, key))
+				if err = p.recordError(err); err != nil {
+					return nil, err
+				}
 			}
 			p.next()
 			return p.maybeWrapNode(&node, object)
 		}
 		oldValue, isDuplicate := object.Set(key, val)
 		if isDuplicate && p.DisallowDuplicateKeys {
-			return nil, p.errAt(fmt.Sprintf("Found duplicate values ('%#v' and '%#v') for key '%v'",
+			err = p.errAt(fmt.Sprintf("Found duplicate values ('%#v' and '%#v') for key '%v'",
 				oldValue, val, key))
+			if err = p.recordError(err); err != nil {
+				return nil, err
+			}
 		}
 		ciBefore = ciAfter
 	}
@@ -764,14 +1265,35 @@ func (p *hjsonParser) readObject(
 		p.setComment1(&node.Cm.InsideLast, ciBefore)
 		return p.maybeWrapNode(&node, object)
 	}
-	return nil, p.errAt("End of input while parsing an object (did you forget a closing '}'?)")
+	err = p.errAt("End of input while parsing an object (did you forget a closing '}'?)")
+	if err = p.recoverFrom(err, closer); err != nil {
+		return nil, err
+	}
+	p.setComment1(&node.Cm.InsideLast, ciBefore)
+	return p.maybeWrapNode(&node, object)
 }
 
 // dest and t must not have been unraveled yet here. In readTfnns we need
 // to check if the original type (or a pointer to it) implements
 // encoding.TextUnmarshaler.
 func (p *hjsonParser) readValue(dest reflect.Value, t reflect.Type) (ret interface{}, err error) {
+	if !p.nodeDestination && isUnmarshalerHjson(t) {
+		// Read a Node tree that can be passed to UnmarshalHjson().
+		willAssign := p.willAssign
+		p.nodeDestination, p.willAssign = true, false
+		defer func() {
+			p.nodeDestination, p.willAssign = false, willAssign
+		}()
+	}
+
 	ciBefore := p.white()
+	start := p.at - 1
+
+	if p.elements++; p.maxElements > 0 && p.elements > p.maxElements {
+		return nil, p.errLimit(fmt.Sprintf("Exceeded the maximum number of values %d (DecoderOptions.MaxElements)",
+			p.maxElements))
+	}
+
 	// Parse an Hjson value. It could be an object, an array, a string, a number or a word.
 	switch p.ch {
 	case '{':
@@ -793,6 +1315,10 @@ func (p *hjsonParser) readValue(dest reflect.Value, t reflect.Type) (ret interfa
 			p.next()
 		}
 	}
+	if err == nil && !p.nodeDestination && isRawMessage(dest, t) {
+		ret = p.rawMessage(start)
+	}
+	p.setPos(ret, start)
 
 	ciAfter := p.getCommentAfter()
 	if p.nodeDestination {
@@ -816,7 +1342,20 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 
 	var errSyntax error
 	var ciAfter commentInfo
+	// Result of parsing a root object without braces, if errors were recovered
+	// from.
+	var objRet interface{}
+	var objErrs ErrorList
 	ciBefore := p.white()
+	start := p.at - 1
+
+	if !p.nodeDestination && isRawMessage(dest, t) {
+		defer func() {
+			if err == nil {
+				ret = p.rawMessage(start)
+			}
+		}()
+	}
 
 	switch p.ch {
 	case '{':
@@ -824,8 +1363,9 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 		if err != nil {
 			return
 		}
+		p.setPos(ret, start)
 		ciAfter, err = p.checkTrailing()
-		if err != nil {
+		if err = p.recordError(err); err != nil {
 			return
 		}
 		if p.nodeDestination {
@@ -840,8 +1380,9 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 		if err != nil {
 			return
 		}
+		p.setPos(ret, start)
 		ciAfter, err = p.checkTrailing()
-		if err != nil {
+		if err = p.recordError(err); err != nil {
 			return
 		}
 		if p.nodeDestination {
@@ -856,9 +1397,17 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 	if ret == nil {
 		// Assume we have a root object without braces.
 		ret, errSyntax = p.readObject(true, dest, t, ciBefore)
+		if errSyntax != nil && errSyntax == p.limitErr {
+			return nil, errSyntax
+		}
+		p.setRootObjectPos(ret, start)
 		ciAfter, err = p.checkTrailing()
-		if errSyntax != nil || err != nil {
+		if errSyntax != nil || err != nil || len(p.errs) > 0 {
 			// Syntax error, or maybe a single JSON value.
+			if errSyntax == nil && err == nil {
+				objRet, objErrs = ret, p.errs
+				p.errs = nil
+			}
 			ret = nil
 			err = nil
 		} else {
@@ -875,15 +1424,16 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 		// test if we are dealing with a single JSON value instead (true/false/null/num/"")
 		p.resetAt()
 		ret, err = p.readValue(dest, t)
-		if err == nil {
+		if err == nil && !p.stream {
 			ciAfter, err = p.checkTrailing()
 		}
-		if err == nil {
+		if err == nil && len(p.errs) == 0 {
 			if p.nodeDestination {
 				if node, ok := ret.(*Node); ok {
 					// ciBefore has been read again and set on the node inside the
 					// function p.readValue().
 					existingAfter := node.Cm.After
+					node.Cm.After = ""
 					p.setComment1(&node.Cm.After, ciAfter)
 					if node.Cm.After != "" {
 						existingAfter += "\n"
@@ -896,6 +1446,12 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 		}
 	}
 
+	if objErrs != nil {
+		// Keep the partially parsed root object.
+		p.errs = objErrs
+		return objRet, nil
+	}
+
 	if errSyntax != nil {
 		return nil, errSyntax
 	}
@@ -903,6 +1459,26 @@ func (p *hjsonParser) rootValue(dest reflect.Value) (ret interface{}, err error)
 	return
 }
 
+// setRootObjectPos is like setPos, but for a root object without braces. The
+// object is considered to end where its last value ends.
+func (p *hjsonParser) setRootObjectPos(value interface{}, start int) {
+	if !p.RecordPositions {
+		return
+	}
+	node, ok := value.(*Node)
+	if !ok {
+		return
+	}
+	sp := Span{Start: p.positionAt(start)}
+	sp.End = sp.Start
+	if om, ok := node.Value.(*OrderedMap); ok && om.Len() > 0 {
+		if elemNode, ok := om.Map[om.Keys[om.Len()-1]].(*Node); ok && elemNode.Pos != nil {
+			sp.End = elemNode.Pos.Value.End
+		}
+	}
+	node.Pos = &NodePos{Value: sp}
+}
+
 func (p *hjsonParser) checkTrailing() (commentInfo, error) {
 	ci := p.white()
 	if p.ch > 0 {
@@ -919,30 +1495,32 @@ func Unmarshal(data []byte, v interface{}) error {
 	return UnmarshalWithOptions(data, v, DefaultDecoderOptions())
 }
 
+func checkDestination(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return rv, fmt.Errorf("Cannot unmarshal into non-pointer %v", reflect.TypeOf(v))
+	}
+	return rv, nil
+}
+
 func orderedUnmarshal(
 	data []byte,
 	v interface{},
 	options DecoderOptions,
-	willMarshalToJSON bool,
+	willAssign bool,
 	nodeDestination bool,
 ) (
 	interface{},
 	error,
 ) {
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return nil, fmt.Errorf("Cannot unmarshal into non-pointer %v", reflect.TypeOf(v))
+	rv, err := checkDestination(v)
+	if err != nil {
+		return nil, err
 	}
 
-	parser := &hjsonParser{
-		DecoderOptions:    options,
-		data:              data,
-		at:                0,
-		ch:                ' ',
-		structTypeCache:   map[reflect.Type]structFieldMap{},
-		willMarshalToJSON: willMarshalToJSON,
-		nodeDestination:   nodeDestination,
-	}
+	parser := newHjsonParser(data, options)
+	parser.willAssign = willAssign
+	parser.nodeDestination = nodeDestination
 	parser.resetAt()
 	value, err := parser.rootValue(rv)
 	if err != nil {
@@ -955,21 +1533,47 @@ func orderedUnmarshal(
 // UnmarshalWithOptions parses the Hjson-encoded data and stores the result
 // in the value pointed to by v.
 //
-// The Hjson input is internally converted to JSON, which is then used as input
-// to the function json.Unmarshal(). Unless the input argument v is of any of
-// these types:
+// The Hjson values are stored in v following the same rules as the function
+// json.Unmarshal(), including calls to UnmarshalJSON() and UnmarshalText() on
+// destinations implementing json.Unmarshaler or encoding.TextUnmarshaler.
+// Unless the input argument v is of any of these types:
 //
 //	*hjson.OrderedMap
 //	**hjson.OrderedMap
 //	*hjson.Node
 //	**hjson.Node
 //
+// If a destination implements hjson.Unmarshaler, UnmarshalHjson() is called
+// with the Node tree parsed for that destination.
+//
 // Comments can be read from the Hjson-encoded data, but only if the input
-// argument v is of type *hjson.Node or **hjson.Node.
+// argument v is of type *hjson.Node or **hjson.Node, or if a destination
+// implements hjson.Unmarshaler.
 //
 // For more details about the output from this function, see the documentation
 // for json.Unmarshal().
 func UnmarshalWithOptions(data []byte, v interface{}, options DecoderOptions) error {
+	return newHjsonParser(data, options).unmarshalData(v)
+}
+
+// unmarshalData parses all of p.data and stores the result in the value
+// pointed to by v.
+func (p *hjsonParser) unmarshalData(v interface{}) error {
+	if p.maxInputBytes > 0 && len(p.data) > p.maxInputBytes {
+		return p.errInputSize()
+	}
+	return p.unmarshal(v, func(rv reflect.Value) (interface{}, error) {
+		p.resetAt()
+		return p.rootValue(rv)
+	})
+}
+
+// unmarshal calls parse to read a value from the input and then stores the
+// result in the value pointed to by v.
+func (p *hjsonParser) unmarshal(
+	v interface{},
+	parse func(rv reflect.Value) (interface{}, error),
+) error {
 	inOM, destinationIsOrderedMap := v.(*OrderedMap)
 	if !destinationIsOrderedMap {
 		pInOM, ok := v.(**OrderedMap)
@@ -990,8 +1594,18 @@ func UnmarshalWithOptions(data []byte, v interface{}, options DecoderOptions) er
 		}
 	}
 
-	value, err := orderedUnmarshal(data, v, options, !(destinationIsOrderedMap ||
-		destinationIsNode), destinationIsNode)
+	rv, err := checkDestination(v)
+	if err != nil {
+		return err
+	}
+
+	p.willAssign = !(destinationIsOrderedMap || destinationIsNode)
+	p.nodeDestination = destinationIsNode
+	if p.willAssign && isUnmarshalerHjson(rv.Type()) {
+		// Read a Node tree that can be passed to UnmarshalHjson().
+		p.nodeDestination, p.willAssign = true, false
+	}
+	value, err := parse(rv)
 	if err != nil {
 		return err
 	}
@@ -999,7 +1613,7 @@ func UnmarshalWithOptions(data []byte, v interface{}, options DecoderOptions) er
 	if destinationIsOrderedMap {
 		if outOM, ok := value.(*OrderedMap); ok {
 			*inOM = *outOM
-			return nil
+			return p.recoveredErrors()
 		}
 		return fmt.Errorf("Cannot unmarshal into hjson.OrderedMap: Try %v as destination instead",
 			reflect.TypeOf(v))
@@ -1008,30 +1622,16 @@ func UnmarshalWithOptions(data []byte, v interface{}, options DecoderOptions) er
 	if destinationIsNode {
 		if outNode, ok := value.(*Node); ok {
 			*inNode = *outNode
-			return nil
+			return p.recoveredErrors()
 		}
 	}
 
-	// Convert to JSON so we can let json.Unmarshal() handle all destination
-	// types (including interfaces json.Unmarshaler and encoding.TextUnmarshaler)
-	// and merging.
-	buf, err := json.Marshal(value)
-	if err != nil {
-		return errors.New("Internal error")
-	}
-
-	dec := json.NewDecoder(bytes.NewBuffer(buf))
-	if options.UseJSONNumber {
-		dec.UseNumber()
-	}
-	if options.DisallowUnknownFields {
-		dec.DisallowUnknownFields()
-	}
-
-	err = dec.Decode(v)
-	if err != nil {
+	err = p.assignRoot(value, rv)
+	if err != nil && len(p.errs) == 0 {
 		return err
 	}
 
-	return err
+	// Errors found while parsing are more relevant than any errors from
+	// assigning the parsed values to the destination.
+	return p.recoveredErrors()
 }
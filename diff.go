@@ -0,0 +1,360 @@
+package hjson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ChangeType is the type of a Change found by Diff().
+type ChangeType int
+
+const (
+	// ChangeAdded means that a value was added at Change.Path.
+	ChangeAdded ChangeType = iota
+	// ChangeRemoved means that the value at Change.Path was removed.
+	ChangeRemoved
+	// ChangeModified means that the value at Change.Path was replaced.
+	ChangeModified
+	// ChangeMoved means that the value at Change.From was moved to Change.Path.
+	ChangeMoved
+)
+
+// String returns the name of the change type.
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	case ChangeMoved:
+		return "moved"
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// Change is a single difference found by Diff().
+type Change struct {
+	Type ChangeType
+	// Path is the JSON Pointer (RFC 6901) to the changed value.
+	Path string
+	// From is the JSON Pointer to the old location of a moved value.
+	From string
+	// OldValue is the removed, replaced or moved value.
+	OldValue interface{}
+	// NewValue is the added, new or moved value.
+	NewValue interface{}
+}
+
+// Changes is a list of differences found by Diff().
+type Changes []Change
+
+// Diff returns the structural differences between a and b, ignoring comments,
+// whitespace, key order and the formatting of numbers. a and b can be Node
+// trees, or any other values that can be marshalled by Marshal(), for example
+// values decoded by Unmarshal().
+//
+// Objects are compared key by key, and arrays element by element after
+// aligning elements that are equal. If a key is removed and another key with
+// an equal value is added, the change is reported as ChangeMoved, as long as
+// the value is an object or an array or both keys have the same name, and
+// neither path goes through an array.
+//
+// The paths in the returned changes refer to the document after all previous
+// changes have been applied, so that applying the changes in order
+// transforms a into b. The values in the changes do not contain any *Node.
+func Diff(a, b interface{}) (Changes, error) {
+	va, err := diffValue(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := diffValue(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var d differ
+	d.diff(nil, true, va, vb)
+	d.findMoves()
+
+	return d.changes, nil
+}
+
+func diffValue(value interface{}) (interface{}, error) {
+	node, err := NodeFrom(value)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapNodes(node), nil
+}
+
+type differ struct {
+	changes Changes
+	// movable[i] is true if changes[i] can be part of a move.
+	movable []bool
+}
+
+func (d *differ) add(change Change, path []string, objectPath bool) {
+	change.Path = formatPointer(path)
+	d.changes = append(d.changes, change)
+	d.movable = append(d.movable, objectPath && len(path) > 0)
+}
+
+// diff appends the changes needed to transform a into b at path. objectPath
+// is true if path does not go through any array.
+func (d *differ) diff(path []string, objectPath bool, a, b interface{}) {
+	switch va := a.(type) {
+	case *OrderedMap:
+		if vb, ok := b.(*OrderedMap); ok {
+			d.diffObject(path, objectPath, va, vb)
+			return
+		}
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			d.diffArray(path, va, vb)
+			return
+		}
+	}
+
+	if !valuesEqual(a, b) {
+		d.add(Change{Type: ChangeModified, OldValue: a, NewValue: b}, path, false)
+	}
+}
+
+func (d *differ) diffObject(path []string, objectPath bool, a, b *OrderedMap) {
+	for _, key := range a.Keys {
+		elemPath := appendToken(path, key)
+		if elem, ok := b.Map[key]; ok {
+			d.diff(elemPath, objectPath, a.Map[key], elem)
+		} else {
+			d.add(Change{Type: ChangeRemoved, OldValue: a.Map[key]}, elemPath, objectPath)
+		}
+	}
+	for _, key := range b.Keys {
+		if _, ok := a.Map[key]; !ok {
+			d.add(Change{Type: ChangeAdded, NewValue: b.Map[key]}, appendToken(path, key), objectPath)
+		}
+	}
+}
+
+// Edit operations used when aligning arrays.
+const (
+	editEqual = iota
+	editDelete
+	editInsert
+)
+
+// maxAlignCells limits the size of the table used to align arrays. Larger
+// arrays are compared index by index.
+const maxAlignCells = 1 << 20
+
+func (d *differ) diffArray(path []string, a, b []interface{}) {
+	edits := alignArrays(a, b)
+
+	// cur is the index in the array after all previous changes are applied.
+	var cur, i, j int
+	for k := 0; k < len(edits); {
+		if edits[k] == editEqual {
+			cur, i, j, k = cur+1, i+1, j+1, k+1
+			continue
+		}
+
+		var dels, ins int
+		for ; k < len(edits) && edits[k] != editEqual; k++ {
+			if edits[k] == editDelete {
+				dels++
+			} else {
+				ins++
+			}
+		}
+
+		// Replaced elements are reported as modified.
+		for ; dels > 0 && ins > 0; dels, ins = dels-1, ins-1 {
+			d.diff(appendToken(path, fmt.Sprint(cur)), false, a[i], b[j])
+			cur, i, j = cur+1, i+1, j+1
+		}
+		for ; dels > 0; dels-- {
+			d.add(Change{Type: ChangeRemoved, OldValue: a[i]}, appendToken(path, fmt.Sprint(cur)), false)
+			i++
+		}
+		for ; ins > 0; ins-- {
+			d.add(Change{Type: ChangeAdded, NewValue: b[j]}, appendToken(path, fmt.Sprint(cur)), false)
+			cur, j = cur+1, j+1
+		}
+	}
+}
+
+// alignArrays returns the edit operations that transform a into b, keeping the
+// longest common subsequence of equal elements.
+func alignArrays(a, b []interface{}) []int {
+	var edits []int
+	if (len(a)+1)*(len(b)+1) > maxAlignCells {
+		for i := 0; i < len(a) || i < len(b); i++ {
+			if i < len(a) {
+				edits = append(edits, editDelete)
+			}
+			if i < len(b) {
+				edits = append(edits, editInsert)
+			}
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if valuesEqual(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && valuesEqual(a[i], b[j]):
+			edits = append(edits, editEqual)
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, editDelete)
+			i++
+		default:
+			edits = append(edits, editInsert)
+			j++
+		}
+	}
+	return edits
+}
+
+// findMoves replaces pairs of removed and added keys with equal values by a
+// single move, at the position of the removal.
+func (d *differ) findMoves() {
+	// moves[i] is the index of the added change paired with the removed
+	// change at index i.
+	moves := map[int]int{}
+	paired := make([]bool, len(d.changes))
+	for i, removed := range d.changes {
+		if removed.Type != ChangeRemoved || !d.movable[i] {
+			continue
+		}
+		for j, added := range d.changes {
+			if !paired[j] && added.Type == ChangeAdded && d.movable[j] &&
+				isMoveCandidate(removed, added) {
+				moves[i] = j
+				paired[j] = true
+				break
+			}
+		}
+	}
+
+	var res Changes
+	for i, change := range d.changes {
+		if paired[i] {
+			continue
+		}
+		if j, ok := moves[i]; ok {
+			change = Change{
+				Type:     ChangeMoved,
+				Path:     d.changes[j].Path,
+				From:     change.Path,
+				OldValue: change.OldValue,
+				NewValue: d.changes[j].NewValue,
+			}
+		}
+		res = append(res, change)
+	}
+	d.changes = res
+}
+
+func isMoveCandidate(removed, added Change) bool {
+	if !valuesEqual(removed.OldValue, added.NewValue) {
+		return false
+	}
+	switch removed.OldValue.(type) {
+	case *OrderedMap, []interface{}:
+		return true
+	}
+	return lastToken(removed.Path) == lastToken(added.Path)
+}
+
+func appendToken(path []string, token string) []string {
+	res := make([]string, len(path), len(path)+1)
+	copy(res, path)
+	return append(res, token)
+}
+
+func lastToken(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// Patch returns the changes as a JSON Patch (RFC 6902), that transforms the
+// first value passed to Diff() into the second value.
+func (changes Changes) Patch() Patch {
+	patch := make(Patch, 0, len(changes))
+	for _, change := range changes {
+		op := PatchOperation{Path: change.Path}
+		switch change.Type {
+		case ChangeAdded:
+			op.Op = "add"
+			op.Value = change.NewValue
+		case ChangeRemoved:
+			op.Op = "remove"
+		case ChangeModified:
+			op.Op = "replace"
+			op.Value = change.NewValue
+		case ChangeMoved:
+			op.Op = "move"
+			op.From = change.From
+		}
+		patch = append(patch, op)
+	}
+	return patch
+}
+
+// Report returns a human readable description of the changes, one change per
+// line, with values written as Hjson:
+//
+//	~ /modified: old value -> new value
+//	+ /added: value
+//	- /removed: value
+//	> /from -> /path
+//
+// Values that span several lines are indented on the lines after the first.
+func (changes Changes) Report() string {
+	var buf bytes.Buffer
+	for _, change := range changes {
+		switch change.Type {
+		case ChangeAdded:
+			fmt.Fprintf(&buf, "+ %s: %s\n", change.Path, reportValue(change.NewValue))
+		case ChangeRemoved:
+			fmt.Fprintf(&buf, "- %s: %s\n", change.Path, reportValue(change.OldValue))
+		case ChangeModified:
+			fmt.Fprintf(&buf, "~ %s: %s -> %s\n", change.Path,
+				reportValue(change.OldValue), reportValue(change.NewValue))
+		case ChangeMoved:
+			fmt.Fprintf(&buf, "> %s -> %s\n", change.From, change.Path)
+		}
+	}
+	return buf.String()
+}
+
+func reportValue(value interface{}) string {
+	opt := DefaultOptions()
+	opt.Comments = false
+	b, err := MarshalWithOptions(value, opt)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.Replace(string(b), "\n", "\n  ", -1)
+}
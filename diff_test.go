@@ -0,0 +1,147 @@
+package hjson
+
+import (
+	"testing"
+)
+
+var diffTextA = `{
+  # comments and formatting are ignored
+  name: app
+  port: 80
+  log: {
+    level: info
+    file: /var/log/app.log
+  }
+  servers: [
+    alpha
+    beta
+    gamma
+  ]
+  old: x
+}`
+
+var diffTextB = `{"port": 80.0, "name": "app",
+  "logging": {"level": "info", "file": "/var/log/app.log"},
+  "servers": ["alpha", "delta", "gamma", "epsilon"],
+  "new": "y"}`
+
+func TestDiff(t *testing.T) {
+	var a, b Node
+	if err := Unmarshal([]byte(diffTextA), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal([]byte(diffTextB), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(&a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `> /log -> /logging
+~ /servers/1: beta -> delta
++ /servers/3: epsilon
+- /old: x
++ /new: y
+`
+	if res := changes.Report(); res != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, res)
+	}
+
+	// Applying the changes as a patch transforms a into b.
+	if err = a.ApplyPatch(changes.Patch()); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err = Diff(&a, &b); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v %v", changes, err)
+	}
+	// The comment is kept by the patch.
+	if a.NK("name").Cm.Before != "  # comments and formatting are ignored\n  " {
+		t.Errorf("Unexpected comment %q", a.NK("name").Cm.Before)
+	}
+}
+
+func TestDiffArrays(t *testing.T) {
+	testCases := []struct {
+		a, b     []interface{}
+		expected string
+	}{
+		{
+			[]interface{}{1, 2, 3},
+			[]interface{}{0, 1, 2, 3},
+			"+ /0: 0\n",
+		},
+		{
+			[]interface{}{1, 2, 3, 4},
+			[]interface{}{1, 4},
+			"- /1: 2\n- /1: 3\n",
+		},
+		{
+			[]interface{}{1, 2, 3},
+			[]interface{}{3, 2, 1},
+			"- /0: 1\n- /0: 2\n+ /1: 2\n+ /2: 1\n",
+		},
+		{
+			[]interface{}{map[string]interface{}{"a": 1, "b": 2}},
+			[]interface{}{map[string]interface{}{"a": 1, "b": 3}, "x"},
+			"~ /0/b: 2 -> 3\n+ /1: x\n",
+		},
+		{
+			[]interface{}{"a", []interface{}{1, 2}},
+			[]interface{}{"a", map[string]interface{}{"x": 1}},
+			"~ /1: [\n    1\n    2\n  ] -> {\n    x: 1\n  }\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		changes, err := Diff(tc.a, tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := changes.Report(); res != tc.expected {
+			t.Errorf("%v -> %v: Expected:\n%s\n\nGot:\n%s", tc.a, tc.b, tc.expected, res)
+		}
+
+		node := &Node{}
+		if err = node.ApplyPatch(Patch{{Op: "add", Path: "", Value: tc.a}}); err != nil {
+			t.Fatal(err)
+		}
+		if err = node.ApplyPatch(changes.Patch()); err != nil {
+			t.Fatal(err)
+		}
+		if changes, err = Diff(node, tc.b); err != nil || len(changes) != 0 {
+			t.Errorf("%v -> %v: Expected no changes after patch, got %v %v", tc.a, tc.b, changes, err)
+		}
+	}
+}
+
+func TestDiffMoves(t *testing.T) {
+	a := map[string]interface{}{
+		"enabled": false,
+		"sub":     map[string]interface{}{"key": "v"},
+		"list":    []interface{}{map[string]interface{}{"x": 1}},
+	}
+	b := map[string]interface{}{
+		"verbose": false,
+		"other":   map[string]interface{}{"key": "v"},
+		"list":    []interface{}{map[string]interface{}{"y": 1}},
+	}
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scalar with a different key name is not a move, and neither is a
+	// change inside an array.
+	expected := `- /enabled: false
+- /list/0/x: 1
++ /list/0/y: 1
+> /sub -> /other
++ /verbose: false
+`
+	if res := changes.Report(); res != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, res)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"regexp"
@@ -61,15 +62,149 @@ func DefaultOptions() EncoderOptions {
 // Start looking for circular references below this depth.
 const depthLimit = 1024
 
+// Buffered output is written to hjsonEncoder.w when it exceeds this size.
+const flushThreshold = 32 * 1024
+
 type hjsonEncoder struct {
 	bytes.Buffer // output
 	EncoderOptions
-	indent          int
+	valueWalker
+	indent int
+	w      io.Writer // If not nil, the output is flushed to w regularly.
+}
+
+// valueWalker contains the state used when walking through Go values. It is
+// shared by hjsonEncoder and nodeBuilder, so that Marshal() and NodeFrom()
+// handle Go values in the same way.
+type valueWalker struct {
 	pDepth          uint
 	parents         map[uintptr]struct{} // Starts to be filled after pDepth has reached depthLimit
 	structTypeCache map[reflect.Type][]structFieldInfo
 }
 
+// enter must be called before walking into value. Returns an error if value
+// is a circular reference. Call leave() with the same value when done.
+func (w *valueWalker) enter(value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if w.pDepth++; w.pDepth > depthLimit {
+			if w.parents == nil {
+				w.parents = map[uintptr]struct{}{}
+			}
+			p := value.Pointer()
+			if _, ok := w.parents[p]; ok {
+				w.pDepth--
+				return errors.New("Circular reference found, pointer of type " + value.Type().String())
+			}
+			w.parents[p] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// leave must be called when done with a value that enter() was called for.
+func (w *valueWalker) leave(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if w.pDepth > depthLimit {
+			delete(w.parents, value.Pointer())
+		}
+		w.pDepth--
+	}
+}
+
+// structFields returns the fields of the struct value that should be
+// encoded, in the order of the struct.
+func (w *valueWalker) structFields(value reflect.Value) []fieldInfo {
+	// Struct field info is identical for all instances of the same type.
+	// Only the values on the fields can be different.
+	t := value.Type()
+	sfis, ok := w.structTypeCache[t]
+	if !ok {
+		sfis = getStructFieldInfoSlice(t)
+		w.structTypeCache[t] = sfis
+	}
+
+	var fis []fieldInfo
+FieldLoop:
+	for _, sfi := range sfis {
+		// The field might be found on the root struct or in embedded structs.
+		fv := value
+		for _, i := range sfi.indexPath {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue FieldLoop
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(i)
+		}
+
+		if sfi.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		fis = append(fis, fieldInfo{
+			field:   fv,
+			name:    sfi.name,
+			comment: sfi.comment,
+		})
+	}
+	return fis
+}
+
+// mapFields returns the elements of the map value, sorted by key.
+func mapFields(value reflect.Value) ([]fieldInfo, error) {
+	var fis []fieldInfo
+	useMarshalText := value.Type().Key().Implements(marshalerText)
+	keys := value.MapKeys()
+	sort.Sort(sortAlpha(keys))
+	for _, key := range keys {
+		var name string
+		if useMarshalText {
+			keyBytes, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			name = string(keyBytes)
+		} else {
+			name = fmt.Sprintf("%v", key)
+		}
+		fis = append(fis, fieldInfo{
+			field: value.MapIndex(key),
+			name:  name,
+		})
+	}
+	return fis, nil
+}
+
+// orderedMapFields returns the elements of om, in the order of om.Keys.
+func orderedMapFields(om OrderedMap) []fieldInfo {
+	var fis []fieldInfo
+	for _, key := range om.Keys {
+		fis = append(fis, fieldInfo{
+			field: reflect.ValueOf(om.Map[key]),
+			name:  key,
+		})
+	}
+	return fis
+}
+
+// flushIfFull writes the buffered output to e.w if e.w is set and the buffer
+// has grown large enough.
+func (e *hjsonEncoder) flushIfFull() error {
+	if e.w != nil && e.Len() >= flushThreshold {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *hjsonEncoder) flush() error {
+	_, err := e.w.Write(e.Bytes())
+	e.Reset()
+	return err
+}
+
 var JSONNumberType = reflect.TypeOf(json.Number(""))
 
 var needsEscape, needsQuotes, needsEscapeML, startsWithKeyword, needsEscapeName *regexp.Regexp
@@ -233,35 +368,103 @@ func (e *hjsonEncoder) writeIndent(indent int) {
 	e.writeIndentNoEOL(indent)
 }
 
-func (e *hjsonEncoder) useMarshalerJSON(
-	value reflect.Value,
-	noIndent bool,
-	separator string,
-	isRootObject,
-	isObjElement bool,
-) error {
-	b, err := value.Interface().(json.Marshaler).MarshalJSON()
-	if err != nil {
-		return err
+// commentString returns the comments at pos in cm in the format used by
+// Unmarshal(). Comments set by Comments.SetText() are returned with each line
+// indented by indent (except for CommentKey, which is a single line following
+// the key), followed by e.Eol and the indentation for next. If next is
+// negative, no indentation is added after the comments. Other comments are
+// returned as is.
+func (e *hjsonEncoder) commentString(cm Comments, pos CommentPos, indent, next int) string {
+	txt := *cm.field(pos)
+	if !cm.isText(pos) {
+		return txt
 	}
 
-	decOpt := DefaultDecoderOptions()
-	decOpt.UseJSONNumber = true
-	var dummyDest interface{}
-	jsonRoot, err := orderedUnmarshal(b, &dummyDest, decOpt, false, false)
-	if err != nil {
-		return err
+	var res string
+	if pos == CommentKey {
+		res = txt + e.Eol
+	} else {
+		for _, line := range strings.Split(strings.TrimSuffix(txt, "\n"), "\n") {
+			if line != "" {
+				res += e.BaseIndentation + strings.Repeat(e.IndentBy, indent) + line
+			}
+			res += e.Eol
+		}
+	}
+	if next >= 0 {
+		res += e.BaseIndentation + strings.Repeat(e.IndentBy, next)
+	}
+	return res
+}
+
+// marshalValue returns the value to use instead of value if value implements
+// json.Marshaler or encoding.TextMarshaler, and true. For json.Marshaler the
+// JSON returned by MarshalJSON() is parsed, so that it can be written as Hjson
+// with the current options. For encoding.TextMarshaler the text returned by
+// MarshalText() is used as a string. Returns value and false if value
+// implements neither of the interfaces.
+func marshalValue(value reflect.Value) (reflect.Value, bool, error) {
+	if value.Type().Implements(marshalerJSON) {
+		b, err := value.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return value, true, err
+		}
+
+		decOpt := DefaultDecoderOptions()
+		decOpt.UseJSONNumber = true
+		var dummyDest interface{}
+		jsonRoot, err := orderedUnmarshal(b, &dummyDest, decOpt, false, false)
+		return reflect.ValueOf(jsonRoot), true, err
+	}
+
+	if value.Type().Implements(marshalerText) {
+		b, err := value.Interface().(encoding.TextMarshaler).MarshalText()
+		return reflect.ValueOf(string(b)), true, err
 	}
 
-	// Output Hjson with our current options, instead of JSON.
-	return e.str(reflect.ValueOf(jsonRoot), noIndent, separator, isRootObject,
-		isObjElement, Comments{})
+	return value, false, nil
 }
 
+// Marshaler is the interface implemented by types that can marshal themselves
+// into an hjson.Node tree, for example to include comments in the output.
+// Marshaler has precedence over json.Marshaler and encoding.TextMarshaler.
+type Marshaler interface {
+	// MarshalHjson returns the Node tree to encode instead of the receiver. The
+	// comments in the returned Node are used instead of any comments from the
+	// parent value, for example the comment tag on a struct field.
+	MarshalHjson() (*Node, error)
+}
+
+var marshalerHjson = reflect.TypeOf((*Marshaler)(nil)).Elem()
 var marshalerJSON = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
 var marshalerText = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 
-func (e *hjsonEncoder) unpackNode(value reflect.Value, cm Comments) (reflect.Value, Comments) {
+// marshalHjson returns the Node returned by MarshalHjson() if value implements
+// hjson.Marshaler, otherwise value. Returns an invalid reflect.Value if
+// MarshalHjson() returns nil.
+func marshalHjson(value reflect.Value) (reflect.Value, error) {
+	if !value.IsValid() || !value.Type().Implements(marshalerHjson) ||
+		(value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+
+		return value, nil
+	}
+
+	node, err := value.Interface().(Marshaler).MarshalHjson()
+	if err != nil || node == nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(node), nil
+}
+
+// unpackNode returns the value wrapped in value, if value is an hjson.Node or
+// implements hjson.Marshaler. If so, cm is replaced by the comments from the
+// Node.
+func (e *hjsonEncoder) unpackNode(value reflect.Value, cm Comments) (reflect.Value, Comments, error) {
+	value, err := marshalHjson(value)
+	if err != nil {
+		return value, cm, err
+	}
+
 	if value.IsValid() {
 		if node, ok := value.Interface().(Node); ok {
 			value = reflect.ValueOf(node.Value)
@@ -276,7 +479,7 @@ func (e *hjsonEncoder) unpackNode(value reflect.Value, cm Comments) (reflect.Val
 		}
 	}
 
-	return value, cm
+	return value, cm, nil
 }
 
 // This function can often be called from within itself, so do not output
@@ -293,7 +496,10 @@ func (e *hjsonEncoder) str(
 	// Produce a string from value.
 
 	// Unpack *Node, possibly overwrite cm.
-	value, cm = e.unpackNode(value, cm)
+	value, cm, err := e.unpackNode(value, cm)
+	if err != nil {
+		return err
+	}
 
 	if cm.Key != "" {
 		separator = ""
@@ -301,21 +507,10 @@ func (e *hjsonEncoder) str(
 
 	kind := value.Kind()
 
-	switch kind {
-	case reflect.Ptr, reflect.Slice, reflect.Map:
-		if e.pDepth++; e.pDepth > depthLimit {
-			if e.parents == nil {
-				e.parents = map[uintptr]struct{}{}
-			}
-			p := value.Pointer()
-			if _, ok := e.parents[p]; ok {
-				return errors.New("Circular reference found, pointer of type " + value.Type().String())
-			}
-			e.parents[p] = struct{}{}
-			defer delete(e.parents, p)
-		}
-		defer func() { e.pDepth-- }()
+	if err := e.enter(value); err != nil {
+		return err
 	}
+	defer e.leave(value)
 
 	if !value.IsValid() {
 		e.WriteString(separator)
@@ -332,32 +527,25 @@ func (e *hjsonEncoder) str(
 		return e.str(value.Elem(), noIndent, separator, isRootObject, isObjElement, cm)
 	}
 
+	// RawMessage implements marshalerJSON, but should be written as is.
+	if value.Type() == rawMessageType {
+		e.writeRaw(value.Bytes(), separator)
+		return nil
+	}
+
 	// Our internal orderedMap implements marshalerJSON. We must therefore place
 	// this check before checking marshalerJSON. Calling orderedMap.MarshalJSON()
 	// from this function would cause an infinite loop.
 	if om, ok := value.Interface().(OrderedMap); ok {
-		var fis []fieldInfo
-		for _, key := range om.Keys {
-			fis = append(fis, fieldInfo{
-				field: reflect.ValueOf(om.Map[key]),
-				name:  key,
-			})
-		}
-		return e.writeFields(fis, noIndent, separator, isRootObject, isObjElement, cm)
+		return e.writeFields(orderedMapFields(om), noIndent, separator, isRootObject, isObjElement, cm)
 	}
 
-	if value.Type().Implements(marshalerJSON) {
-		return e.useMarshalerJSON(value, noIndent, separator, isRootObject, isObjElement)
-	}
-
-	if value.Type().Implements(marshalerText) {
-		b, err := value.Interface().(encoding.TextMarshaler).MarshalText()
+	if mv, ok, err := marshalValue(value); ok {
 		if err != nil {
 			return err
 		}
-
-		return e.str(reflect.ValueOf(string(b)), noIndent, separator, isRootObject,
-			isObjElement, cm)
+		// Output Hjson with our current options, instead of JSON.
+		return e.str(mv, noIndent, separator, isRootObject, isObjElement, cm)
 	}
 
 	switch kind {
@@ -414,6 +602,7 @@ func (e *hjsonEncoder) str(
 		e.WriteString("[" + cm.InsideFirst)
 
 		if value.Len() == 0 {
+			cm.InsideLast = e.commentString(cm, CommentInsideLast, e.indent+1, e.indent)
 			if cm.InsideFirst != "" || cm.InsideLast != "" {
 				e.WriteString(e.Eol)
 				if cm.InsideLast == "" {
@@ -426,10 +615,16 @@ func (e *hjsonEncoder) str(
 
 		indent1 := e.indent
 		e.indent++
+		cm.InsideLast = e.commentString(cm, CommentInsideLast, e.indent, indent1)
 
 		// Join all of the element texts together, separated with newlines
 		for i := 0; i < value.Len(); i++ {
-			elem, elemCm := e.unpackNode(value.Index(i), Comments{})
+			elem, elemCm, err := e.unpackNode(value.Index(i), Comments{})
+			if err != nil {
+				return err
+			}
+			elemCm.Before = e.commentString(elemCm, CommentBefore, e.indent, e.indent)
+			elemCm.Key = e.commentString(elemCm, CommentKey, e.indent, e.indent)
 
 			if elemCm.Before == "" && elemCm.Key == "" {
 				e.writeIndent(e.indent)
@@ -442,6 +637,10 @@ func (e *hjsonEncoder) str(
 			}
 
 			e.WriteString(elemCm.After)
+
+			if err := e.flushIfFull(); err != nil {
+				return err
+			}
 		}
 
 		if cm.InsideLast != "" {
@@ -454,69 +653,16 @@ func (e *hjsonEncoder) str(
 		e.indent = indent1
 
 	case reflect.Map:
-		var fis []fieldInfo
-		useMarshalText := value.Type().Key().Implements(marshalerText)
-		keys := value.MapKeys()
-		sort.Sort(sortAlpha(keys))
-		for _, key := range keys {
-			var name string
-			if useMarshalText {
-				keyBytes, err := key.Interface().(encoding.TextMarshaler).MarshalText()
-				if err != nil {
-					return err
-				}
-				name = string(keyBytes)
-			} else {
-				name = fmt.Sprintf("%v", key)
-			}
-			fis = append(fis, fieldInfo{
-				field: value.MapIndex(key),
-				name:  name,
-			})
+		fis, err := mapFields(value)
+		if err != nil {
+			return err
 		}
 		return e.writeFields(fis, noIndent, separator, isRootObject, isObjElement, cm)
 
 	case reflect.Struct:
-		// Struct field info is identical for all instances of the same type.
-		// Only the values on the fields can be different.
-		t := value.Type()
-		sfis, ok := e.structTypeCache[t]
-		if !ok {
-			sfis = getStructFieldInfoSlice(t)
-			e.structTypeCache[t] = sfis
-		}
-
 		// Collect fields first, too see if any should be shown (considering
 		// "omitEmpty").
-		var fis []fieldInfo
-	FieldLoop:
-		for _, sfi := range sfis {
-			// The field might be found on the root struct or in embedded structs.
-			fv := value
-			for _, i := range sfi.indexPath {
-				if fv.Kind() == reflect.Ptr {
-					if fv.IsNil() {
-						continue FieldLoop
-					}
-					fv = fv.Elem()
-				}
-				fv = fv.Field(i)
-			}
-
-			if sfi.omitEmpty && isEmptyValue(fv) {
-				continue
-			}
-
-			fi := fieldInfo{
-				field: fv,
-				name:  sfi.name,
-			}
-			if e.Comments {
-				fi.comment = sfi.comment
-			}
-			fis = append(fis, fi)
-		}
-		return e.writeFields(fis, noIndent, separator, isRootObject, isObjElement, cm)
+		return e.writeFields(e.structFields(value), noIndent, separator, isRootObject, isObjElement, cm)
 
 	default:
 		return errors.New("Unsupported type " + value.Type().String())
@@ -689,21 +835,34 @@ func Marshal(v interface{}) ([]byte, error) {
 // them. Passing cyclic structures to Marshal will result in an error.
 func MarshalWithOptions(v interface{}, options EncoderOptions) ([]byte, error) {
 	e := &hjsonEncoder{
-		indent:          0,
-		EncoderOptions:  options,
-		structTypeCache: map[reflect.Type][]structFieldInfo{},
+		indent:         0,
+		EncoderOptions: options,
+		valueWalker: valueWalker{
+			structTypeCache: map[reflect.Type][]structFieldInfo{},
+		},
 	}
 
-	value := reflect.ValueOf(v)
-	_, cm := e.unpackNode(value, Comments{})
-	e.WriteString(cm.Before + cm.Key)
-
-	err := e.str(value, true, e.BaseIndentation, true, false, cm)
+	err := e.encode(v)
 	if err != nil {
 		return nil, err
 	}
 
+	return e.Bytes(), nil
+}
+
+func (e *hjsonEncoder) encode(v interface{}) error {
+	value, cm, err := e.unpackNode(reflect.ValueOf(v), Comments{})
+	if err != nil {
+		return err
+	}
+	e.WriteString(e.commentString(cm, CommentBefore, 0, -1) + cm.Key)
+
+	err = e.str(value, true, e.BaseIndentation, true, false, cm)
+	if err != nil {
+		return err
+	}
+
 	e.WriteString(cm.After)
 
-	return e.Bytes(), nil
+	return nil
 }
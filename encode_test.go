@@ -875,3 +875,35 @@ func TestStructComment(t *testing.T) {
 		t.Errorf("Expected:\n%s\nGot:\n%s\n\n", expected, string(h))
 	}
 }
+
+func TestMarshalerHjson(t *testing.T) {
+	type tsA struct {
+		A hjsonCommented
+		B *hjsonCommented
+		C []hjsonCommented
+	}
+
+	h, err := Marshal(tsA{
+		A: hjsonCommented{1, "first"},
+		C: []hjsonCommented{{3, "third"}, {4, ""}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, h, `{
+  A: 1 # first
+  B: null
+  C: [
+    3 # third
+    4
+  ]
+}`)
+
+	var v tsA
+	if err = Unmarshal(h, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.Comment != "first" || v.C[0].Comment != "third" {
+		t.Errorf("Comments were not preserved: %#v", v)
+	}
+}
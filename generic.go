@@ -0,0 +1,42 @@
+//go:build go1.18
+// +build go1.18
+
+package hjson
+
+import (
+	"fmt"
+	"os"
+)
+
+// UnmarshalAs returns a value of the type T read from the Hjson data, using
+// the same rules as UnmarshalWithOptions(). If no options are specified,
+// DefaultDecoderOptions() is used. Only the first of options is used.
+//
+// If an error is returned, the returned value might have been partially
+// filled in, as for UnmarshalWithOptions().
+func UnmarshalAs[T any](data []byte, options ...DecoderOptions) (T, error) {
+	opt := DefaultDecoderOptions()
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	var v T
+	err := UnmarshalWithOptions(data, &v, opt)
+	return v, err
+}
+
+// ReadFile reads the file named by filename and returns a value of the type T
+// read from its contents, like UnmarshalAs(). Errors from reading and
+// unmarshalling the file include filename, and wrap the original error so
+// that it can be found using errors.As(), for example a *SyntaxError.
+func ReadFile[T any](filename string, options ...DecoderOptions) (T, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		var v T
+		return v, err
+	}
+	v, err := UnmarshalAs[T](data, options...)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", filename, err)
+	}
+	return v, err
+}
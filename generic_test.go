@@ -0,0 +1,79 @@
+//go:build go1.18
+// +build go1.18
+
+package hjson
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalAs(t *testing.T) {
+	type config struct {
+		Name  string
+		Ports []int
+	}
+
+	c, err := UnmarshalAs[config]([]byte("name: 3\nports: [1, 2]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, config{"3", []int{1, 2}}) {
+		t.Errorf("Unexpected value: %+v", c)
+	}
+
+	v, err := UnmarshalAs[interface{}]([]byte("a: 1"), DecoderOptions{UseJSONNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if om, ok := v.(map[string]interface{}); !ok || om["a"] != json.Number("1") {
+		t.Errorf("Unexpected value: %#v", v)
+	}
+
+	node, err := UnmarshalAs[*Node]([]byte("a: 1 # one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.NK("a").Cm.After != " # one" {
+		t.Errorf("Unexpected comment: %q", node.NK("a").Cm.After)
+	}
+
+	if _, err = UnmarshalAs[config]([]byte("{name: x")); err == nil {
+		t.Error("Expected an error for invalid input")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.hjson")
+	if err := os.WriteFile(filename, []byte("{\n  a: 1\n  b: [\n}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadFile[map[string]interface{}](filename)
+	var syntaxErr *SyntaxError
+	if err == nil || !strings.HasPrefix(err.Error(), filename+": ") || !errors.As(err, &syntaxErr) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if syntaxErr.Line != 4 {
+		t.Errorf("Expected the error on line 4, got %d", syntaxErr.Line)
+	}
+
+	if err := os.WriteFile(filename, []byte("a: 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadFile[map[string]int](filename)
+	if err != nil || m["a"] != 1 {
+		t.Errorf("Unexpected value %v and error %v", m, err)
+	}
+
+	_, err = ReadFile[map[string]int](filepath.Join(dir, "missing.hjson"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
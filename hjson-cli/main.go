@@ -25,13 +25,51 @@ func fixJSON(data []byte) []byte {
 	return data
 }
 
+func readValue(filename string) interface{} {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		panic(err)
+	}
+	var node *hjson.Node
+	if err = hjson.Unmarshal(data, &node); err != nil {
+		panic(fmt.Errorf("%s: %v", filename, err))
+	}
+	return node
+}
+
+// diff prints the structural differences between the files a and b, as a
+// report or as a JSON Patch. Exits with status 1 if there are differences.
+func diff(a, b string, showPatch bool, indentBy string) {
+	changes, err := hjson.Diff(readValue(a), readValue(b))
+	if err != nil {
+		panic(err)
+	}
+
+	if showPatch {
+		out, err := json.MarshalIndent(changes.Patch(), "", indentBy)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(fixJSON(out)))
+	} else {
+		fmt.Print(changes.Report())
+	}
+
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
 func main() {
 
 	flag.Usage = func() {
 		fmt.Println("usage: hjson-cli [OPTIONS] [INPUT]")
+		fmt.Println("       hjson-cli -diff [-patch] A B")
 		fmt.Println("hjson can be used to convert JSON from/to Hjson.")
 		fmt.Println("")
 		fmt.Println("hjson will read the given JSON/Hjson input file or read from stdin.")
+		fmt.Println("With -diff, hjson compares the JSON/Hjson files A and B, ignoring comments")
+		fmt.Println("and formatting, and exits with status 1 if there are differences.")
 		fmt.Println("")
 		fmt.Println("Options:")
 		flag.PrintDefaults()
@@ -47,9 +85,11 @@ func main() {
 	var quoteAlways = flag.Bool("quoteAlways", false, "Always quote string values.")
 	var showVersion = flag.Bool("v", false, "Show version.")
 	var preserveKeyOrder = flag.Bool("preserveKeyOrder", false, "Preserve key order in objects/maps.")
+	var showDiff = flag.Bool("diff", false, "Show the structural differences between two files.")
+	var showPatch = flag.Bool("patch", false, "With -diff, output the differences as JSON Patch (RFC 6902).")
 
 	flag.Parse()
-	if *help || flag.NArg() > 1 {
+	if *help || (!*showDiff && flag.NArg() > 1) || (*showDiff && flag.NArg() != 2) {
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -65,6 +105,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *showDiff {
+		diff(flag.Arg(0), flag.Arg(1), *showPatch, *indentBy)
+		return
+	}
+
 	var err error
 	var data []byte
 	if flag.NArg() == 1 {
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func fixEOL(data []byte) []byte {
@@ -1785,3 +1787,570 @@ j: null, k: "another text", l: null
 		t.Error("Should have failed, should not be possible to call pointer method UnmarshalText() on the map elements because they are not addressable.")
 	}
 }
+
+func TestSyntaxError(t *testing.T) {
+	testCases := []struct {
+		input  string
+		err    SyntaxError
+		errMsg string
+	}{
+		{
+			input: "{\n  a: 1\n  b c: 2\n}",
+			err: SyntaxError{
+				Msg:     "Found whitespace in your key name (use quotes to include)",
+				Offset:  12,
+				Line:    3,
+				Column:  4,
+				Excerpt: "  b c: 2\n}",
+			},
+			errMsg: "Found whitespace in your key name (use quotes to include) at line 3,4 >>>   b c: 2\n}",
+		},
+		{
+			input: `["a\q"]`,
+			err: SyntaxError{
+				Msg:     `Bad escape \q`,
+				Offset:  4,
+				Line:    1,
+				Column:  5,
+				Excerpt: `["a\q"]`,
+			},
+			// The message keeps the format used before SyntaxError was added,
+			// in which columns on the first line are 0-based and the excerpt
+			// skips the first byte.
+			errMsg: `Bad escape \q at line 1,4 >>> "a\q"]`,
+		},
+		{
+			input: "\n[\n  \"a\n\"]",
+			err: SyntaxError{
+				Msg:     "Bad string containing newline",
+				Offset:  7,
+				Line:    3,
+				Column:  5,
+				Excerpt: "  \"a\n\"]",
+			},
+			errMsg: "Bad string containing newline at line 3,0 >>> \"]",
+		},
+		{
+			input: "[\n  1\n",
+			err: SyntaxError{
+				Msg:    "End of input while parsing an array (did you forget a closing ']'?)",
+				Offset: 6,
+				Line:   3,
+				Column: 1,
+			},
+			errMsg: "End of input while parsing an array (did you forget a closing ']'?)",
+		},
+	}
+
+	for _, tc := range testCases {
+		var v interface{}
+		err := Unmarshal([]byte(tc.input), &v)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Expected *SyntaxError for input %q, got %#v", tc.input, err)
+			continue
+		}
+		// The message is checked below.
+		got := *syntaxErr
+		got.text = ""
+		if got != tc.err {
+			t.Errorf("Expected %#v, got %#v", tc.err, got)
+		}
+		if err.Error() != tc.errMsg {
+			t.Errorf("Expected error message %q, got %q", tc.errMsg, err.Error())
+		}
+	}
+}
+
+func TestRecoverFromErrors(t *testing.T) {
+	txt := `{
+  a: 1
+  b c: 2
+  d: "bad \q escape"
+  e: [1, :, 3]
+  f: {
+    g 1
+    h: true
+  }
+  i: 3
+`
+	decOpt := DefaultDecoderOptions()
+	decOpt.RecoverFromErrors = true
+
+	var node Node
+	err := UnmarshalWithOptions([]byte(txt), &node, decOpt)
+	errList, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected ErrorList, got %#v", err)
+	}
+	expectedLines := []int{3, 4, 5, 7, 11}
+	if len(errList) != len(expectedLines) {
+		t.Fatalf("Expected %d errors, got:\n%v", len(expectedLines), errList)
+	}
+	for i, line := range expectedLines {
+		if errList[i].Line != line {
+			t.Errorf("Expected error %d on line %d, got: %v", i, line, errList[i])
+		}
+	}
+
+	decOpt.WhitespaceAsComments = false
+	var v interface{}
+	if err := UnmarshalWithOptions([]byte(txt), &v, decOpt); err == nil {
+		t.Fatal("Expected an error")
+	}
+	expected := map[string]interface{}{
+		"a": 1.0,
+		"e": []interface{}{1.0, 3.0},
+		"f": map[string]interface{}{"h": true},
+		"i": 3.0,
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+
+	// Single values and valid input should not be affected.
+	for _, txt := range []string{`"abc"`, `null`, `3`, `a: 1`, `[1]`} {
+		if err := UnmarshalWithOptions([]byte(txt), &v, decOpt); err != nil {
+			t.Errorf("Unexpected error for %s: %v", txt, err)
+		}
+	}
+}
+
+func TestStringOption(t *testing.T) {
+	type tsA struct {
+		A int     `json:",string"`
+		B bool    `json:",string"`
+		C *string `json:",string"`
+		D float64 `json:",string"`
+	}
+
+	var sA tsA
+	err := Unmarshal([]byte(`a: "3", b: "true", c: "\"x\"", d: "1.5"`), &sA)
+	if err != nil {
+		t.Error(err)
+	} else if sA.A != 3 || !sA.B || sA.C == nil || *sA.C != "x" || sA.D != 1.5 {
+		t.Errorf("Unexpected struct values: %#v\n", sA)
+	}
+
+	if err = Unmarshal([]byte(`a: 3`), &sA); err == nil {
+		t.Error("Should have failed, value for ,string field is not quoted.")
+	}
+	if err = Unmarshal([]byte(`b: "yes"`), &sA); err == nil {
+		t.Error("Should have failed, quoted value is not a bool.")
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	type tsB struct {
+		C []int
+	}
+	type tsA struct {
+		A string
+		B tsB
+	}
+
+	var sA tsA
+	err := Unmarshal([]byte("a: x\nb: {c: [1, true, 3]}"), &sA)
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("Expected *json.UnmarshalTypeError, got %#v", err)
+	}
+	if typeErr.Value != "bool" || typeErr.Field != "B.C" || typeErr.Struct != "tsB" {
+		t.Errorf("Unexpected error: %#v", typeErr)
+	}
+	// The other values should still have been assigned.
+	if sA.A != "x" || !reflect.DeepEqual(sA.B.C, []int{1, 0, 3}) {
+		t.Errorf("Unexpected struct values: %#v\n", sA)
+	}
+
+	var i8 int8
+	if err = Unmarshal([]byte(`300`), &i8); err == nil {
+		t.Error("Should have failed, 300 overflows int8.")
+	}
+	var ui uint
+	if err = Unmarshal([]byte(`-1`), &ui); err == nil {
+		t.Error("Should have failed, -1 is not an uint.")
+	}
+}
+
+func TestUnmarshalIntoExisting(t *testing.T) {
+	type tsA struct {
+		A []int
+		B [3]int
+		C map[string]int
+		D *int
+		E int
+	}
+
+	d := 5
+	sA := tsA{
+		A: make([]int, 4, 10),
+		B: [3]int{7, 8, 9},
+		C: map[string]int{"x": 1},
+		D: &d,
+		E: 6,
+	}
+	err := Unmarshal([]byte(`a: [1, 2], b: [1], c: {y: 2}, d: 3`), &sA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sA, tsA{
+		A: []int{1, 2},
+		B: [3]int{1, 0, 0},
+		C: map[string]int{"x": 1, "y": 2},
+		D: &d,
+		E: 6,
+	}) || d != 3 {
+		t.Errorf("Unexpected struct values: %#v\n", sA)
+	}
+
+	var b []byte
+	if err = Unmarshal([]byte(`"aGVsbG8="`), &b); err != nil {
+		t.Error(err)
+	} else if string(b) != "hello" {
+		t.Errorf("Unexpected value: %q", b)
+	}
+}
+
+var benchmarkText = []byte(`
+# A typical configuration file.
+name: benchmark
+version: 3
+enabled: true
+ratio: 0.75
+tags: [
+  one
+  two
+  three
+]
+servers: [
+  { host: "alpha", port: 8080, weights: [1, 2, 3] }
+  { host: "beta", port: 8081, weights: [4, 5, 6] }
+  { host: "gamma", port: 8082, weights: [7, 8, 9] }
+]
+limits: {
+  cpu: 4
+  memory: 2048
+}
+`)
+
+type benchmarkServer struct {
+	Host    string
+	Port    int
+	Weights []int
+}
+
+type benchmarkConfig struct {
+	Name    string
+	Version int
+	Enabled bool
+	Ratio   float64
+	Tags    []string
+	Servers []benchmarkServer
+	Limits  map[string]int
+}
+
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v benchmarkConfig
+		if err := Unmarshal(benchmarkText, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalInterface(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v interface{}
+		if err := Unmarshal(benchmarkText, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUnmarshalStructViaJSON converts the input to JSON and then calls
+// json.Unmarshal(), for comparison with BenchmarkUnmarshalStruct.
+func BenchmarkUnmarshalStructViaJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v benchmarkConfig
+		value, err := orderedUnmarshal(benchmarkText, &v, DefaultDecoderOptions(), true, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf, err := json.Marshal(value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = json.Unmarshal(buf, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type hjsonCommented struct {
+	Value   int
+	Comment string
+}
+
+func (c hjsonCommented) MarshalHjson() (*Node, error) {
+	node := &Node{Value: c.Value}
+	if c.Comment != "" {
+		node.Cm.After = " # " + c.Comment
+	}
+	return node, nil
+}
+
+func (c hjsonCommented) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("MarshalJSON should not be called")
+}
+
+func (c *hjsonCommented) UnmarshalHjson(node *Node) error {
+	n, ok := node.Value.(float64)
+	if !ok {
+		return fmt.Errorf("Expected a number, got %#v", node.Value)
+	}
+	c.Value = int(n)
+	c.Comment = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(node.Cm.After), "#"))
+	return nil
+}
+
+func (c *hjsonCommented) UnmarshalJSON(b []byte) error {
+	return errors.New("UnmarshalJSON should not be called")
+}
+
+func TestUnmarshalerHjson(t *testing.T) {
+	type tsA struct {
+		A hjsonCommented
+		B *hjsonCommented
+		C []hjsonCommented
+		D int
+	}
+
+	txt := []byte(`
+a: 1 # first
+b: 2 # second
+c: [
+  3 # third
+  4
+]
+d: 5
+`)
+	var sA tsA
+	if err := Unmarshal(txt, &sA); err != nil {
+		t.Fatal(err)
+	}
+	expected := tsA{
+		A: hjsonCommented{1, "first"},
+		B: &hjsonCommented{2, "second"},
+		C: []hjsonCommented{{3, "third"}, {4, ""}},
+		D: 5,
+	}
+	if !reflect.DeepEqual(sA, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, sA)
+	}
+
+	var root hjsonCommented
+	if err := Unmarshal([]byte(`6 # root`), &root); err != nil {
+		t.Fatal(err)
+	}
+	if root != (hjsonCommented{6, "root"}) {
+		t.Errorf("Unexpected value: %#v", root)
+	}
+
+	if err := Unmarshal([]byte(`a: x`), &sA); err == nil {
+		t.Error("Expected the error from UnmarshalHjson() to be returned")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	deep := strings.Repeat("[", DefaultMaxDepth+1) + strings.Repeat("]", DefaultMaxDepth+1)
+	var v interface{}
+	err := Unmarshal([]byte(deep), &v)
+	if err == nil || !strings.Contains(err.Error(), "MaxDepth") {
+		t.Errorf("Expected MaxDepth error, got %v", err)
+	}
+
+	decOpt := DefaultDecoderOptions()
+	decOpt.MaxDepth = -1
+	if err = UnmarshalWithOptions([]byte(deep), &v, decOpt); err != nil {
+		t.Errorf("Unexpected error with disabled MaxDepth: %v", err)
+	}
+
+	testCases := []struct {
+		opt   DecoderOptions
+		ok    string
+		fail  string
+		limit string
+	}{
+		{DecoderOptions{MaxDepth: 2}, `a: {b: 1}`, `a: {b: [1]}`, "MaxDepth"},
+		{DecoderOptions{MaxDepth: 2}, `[[1]]`, `[{a: [1]}]`, "MaxDepth"},
+		{DecoderOptions{MaxInputBytes: 10}, `[1, 2, 3]`, `[1, 2, 3, 4]`, "MaxInputBytes"},
+		{DecoderOptions{MaxStringLength: 3}, `abc: "abc"`, `abcd: 1`, "MaxStringLength"},
+		{DecoderOptions{MaxStringLength: 3}, `a: abc`, `a: abcd`, "MaxStringLength"},
+		{DecoderOptions{MaxStringLength: 3}, `a: "Abc"`, `a: "abcd"`, "MaxStringLength"},
+		{DecoderOptions{MaxStringLength: 3}, "a: '''\n  abc\n  '''", "a: '''\n  abcd\n  '''", "MaxStringLength"},
+		{DecoderOptions{MaxElements: 4}, `a: [1, 2], b: 3`, `a: [1, 2, 3], b: 4`, "MaxElements"},
+		{DecoderOptions{MaxElements: 4, RecoverFromErrors: true}, `[1, 2, 3]`, `[1, 2, 3, 4, 5]`, "MaxElements"},
+	}
+	for i, tc := range testCases {
+		if err = UnmarshalWithOptions([]byte(tc.ok), &v, tc.opt); err != nil {
+			t.Errorf("%d: Unexpected error for %s: %v", i, tc.ok, err)
+		}
+		err = UnmarshalWithOptions([]byte(tc.fail), &v, tc.opt)
+		if err == nil || !strings.Contains(err.Error(), tc.limit) {
+			t.Errorf("%d: Expected %s error for %s, got %v", i, tc.limit, tc.fail, err)
+		}
+		if _, ok := err.(ErrorList); ok {
+			t.Errorf("%d: Limit errors should not be recovered from: %v", i, err)
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader(`[1, 2, 3] [4, 5, 6, 7, 8, 9, 10]`))
+	dec.SetMaxInputBytes(16)
+	if err = dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err = dec.Decode(&v); err == nil || !strings.Contains(err.Error(), "MaxInputBytes") {
+		t.Errorf("Expected MaxInputBytes error, got %v", err)
+	}
+
+	// A value ending exactly at the limit is accepted, also by a Decoder.
+	txt := `{"a": 1}`
+	decOpt = DefaultDecoderOptions()
+	decOpt.MaxInputBytes = len(txt)
+	if err = UnmarshalWithOptions([]byte(txt), &v, decOpt); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, r := range []io.Reader{strings.NewReader(txt), iotest.OneByteReader(strings.NewReader(txt))} {
+		dec = NewDecoder(r)
+		dec.SetMaxInputBytes(len(txt))
+		if err = dec.Decode(&v); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err = dec.Decode(&v); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	}
+	dec = NewDecoder(strings.NewReader(txt + " "))
+	dec.SetMaxInputBytes(len(txt))
+	if err = dec.Decode(&v); err == nil || !strings.Contains(err.Error(), "MaxInputBytes") {
+		t.Errorf("Expected MaxInputBytes error, got %v", err)
+	}
+
+	// Zero options use the default limits, negative options disable the limits.
+	p := newHjsonParser(nil, DecoderOptions{MaxElements: -1})
+	if p.maxDepth != DefaultMaxDepth || p.maxInputBytes != DefaultMaxInputBytes ||
+		p.maxStringLength != DefaultMaxStringLength || p.maxElements != 0 {
+		t.Errorf("Unexpected limits: %d, %d, %d, %d", p.maxDepth, p.maxInputBytes, p.maxStringLength,
+			p.maxElements)
+	}
+	if p = newHjsonParser(nil, DecoderOptions{MaxDepth: -5}); p.maxDepth != 0 {
+		t.Errorf("Expected no depth limit, got %d", p.maxDepth)
+	}
+	if opt := DefaultDecoderOptions(); opt.MaxDepth != DefaultMaxDepth || opt.MaxInputBytes != DefaultMaxInputBytes ||
+		opt.MaxStringLength != DefaultMaxStringLength || opt.MaxElements != DefaultMaxElements {
+		t.Errorf("Unexpected default limits: %+v", opt)
+	}
+}
+
+type testDefaultsBase struct {
+	Timeout int    `default:"30"`
+	Mode    string `json:"mode" default:"fast"`
+}
+
+type testDefaultsServer struct {
+	Host string `default:"localhost"`
+	Port string `default:"8080"`
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	type config struct {
+		testDefaultsBase
+		*testDefaultsServer `json:"-"`
+		Name                string         `default:"unnamed"`
+		Tags                []string       `default:"[\"a\", \"b c\"]"`
+		Limits              map[string]int `default:"{cpu: 2, mem: 512}"`
+		Server              testDefaultsServer
+		Backup              *testDefaultsServer
+		Extra               *Node `default:"{x: 1} # extra"`
+		Plain               string
+		Labels              map[string]string `default:"{a: \"1\"}"`
+	}
+
+	var c config
+	err := Unmarshal([]byte("name: x\nserver: {\nport: 9000\n}\nmode: \"\"\nlabels: {}"), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		testDefaultsBase: testDefaultsBase{Timeout: 30},
+		Name:             "x",
+		Tags:             []string{"a", "b c"},
+		Limits:           map[string]int{"cpu": 2, "mem": 512},
+		Server:           testDefaultsServer{Host: "localhost", Port: "9000"},
+		Labels:           map[string]string{},
+	}
+	extra := c.Extra
+	c.Extra = nil
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Expected:\n%+v\n\nGot:\n%+v", expected, c)
+	}
+	if extra.NK("x").Value != 1.0 || extra.Cm.After != " # extra" {
+		t.Errorf("Unexpected Node default: %#v", extra)
+	}
+
+	// Defaults are also set in structs in maps and slices, and for objects
+	// decoded from a Node tree.
+	var servers map[string][]testDefaultsServer
+	if err = Unmarshal([]byte("a: [\n{\nhost: h\n}\n{}\n]"), &servers); err != nil {
+		t.Fatal(err)
+	}
+	expectedServers := map[string][]testDefaultsServer{
+		"a": {{"h", "8080"}, {"localhost", "8080"}},
+	}
+	if !reflect.DeepEqual(servers, expectedServers) {
+		t.Errorf("Expected %v, got %v", expectedServers, servers)
+	}
+	var base testDefaultsBase
+	if err = (&Node{Value: NewOrderedMap()}).Decode(&base, DefaultDecoderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if base != (testDefaultsBase{30, "fast"}) {
+		t.Errorf("Unexpected value: %+v", base)
+	}
+
+	// Layered configuration: values from an earlier Unmarshal() are kept,
+	// only fields that still hold their zero values get defaults.
+	var layered testDefaultsServer
+	if err = Unmarshal([]byte("port: \"9000\""), &layered); err != nil {
+		t.Fatal(err)
+	}
+	if err = Unmarshal([]byte("host: example.com"), &layered); err != nil {
+		t.Fatal(err)
+	}
+	if layered != (testDefaultsServer{"example.com", "9000"}) {
+		t.Errorf("Unexpected layered value: %+v", layered)
+	}
+
+	// Each struct gets its own copy of a default value.
+	var configs []config
+	if err = Unmarshal([]byte("[{}, {}]"), &configs); err != nil {
+		t.Fatal(err)
+	}
+	configs[0].Tags[0] = "changed"
+	configs[0].Limits["cpu"] = 4
+	if configs[1].Tags[0] != "a" || configs[1].Limits["cpu"] != 2 {
+		t.Errorf("Default values are shared: %v %v", configs[1].Tags, configs[1].Limits)
+	}
+
+	var bad struct {
+		N int `default:"x"`
+	}
+	err = Unmarshal([]byte("{}"), &bad)
+	if err == nil || !strings.Contains(err.Error(), "Invalid default value for field N") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
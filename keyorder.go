@@ -0,0 +1,292 @@
+package hjson
+
+import (
+	"sort"
+)
+
+// keyBlockSize is the number of keys in each block of a keyOrder when it is
+// built. A block is split in two when it grows to more than twice this size.
+const keyBlockSize = 256
+
+// keyOrder holds keys in order, split into blocks, so that a key can be looked
+// up, inserted or deleted at any position by only moving the keys of a single
+// block.
+type keyOrder struct {
+	blocks []*keyBlock
+	// where maps each key to the block that contains it.
+	where map[string]*keyBlock
+	// n is the total number of keys.
+	n int
+}
+
+type keyBlock struct {
+	keys []string
+	// index is the position of the block in keyOrder.blocks, and first is the
+	// position of keys[0] in the whole order.
+	index int
+	first int
+}
+
+func newKeyBlock() *keyBlock {
+	return &keyBlock{keys: make([]string, 0, 2*keyBlockSize+1)}
+}
+
+func newKeyOrder(keys []string) *keyOrder {
+	o := &keyOrder{
+		where: make(map[string]*keyBlock, len(keys)),
+		n:     len(keys),
+	}
+	for start := 0; start < len(keys); start += keyBlockSize {
+		end := start + keyBlockSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		b := newKeyBlock()
+		b.keys = append(b.keys, keys[start:end]...)
+		b.index = len(o.blocks)
+		b.first = start
+		for _, key := range b.keys {
+			o.where[key] = b
+		}
+		o.blocks = append(o.blocks, b)
+	}
+	return o
+}
+
+// find returns the block containing the key at position pos, and the position
+// of the key in that block. Panics if pos < 0 or pos >= o.n.
+func (o *keyOrder) find(pos int) (*keyBlock, int) {
+	if pos < 0 || pos >= o.n {
+		panic("hjson: index out of range")
+	}
+	i := sort.Search(len(o.blocks), func(i int) bool {
+		return o.blocks[i].first > pos
+	}) - 1
+	b := o.blocks[i]
+	return b, pos - b.first
+}
+
+// at returns the key at position pos.
+func (o *keyOrder) at(pos int) string {
+	b, i := o.find(pos)
+	return b.keys[i]
+}
+
+// indexOf returns the position of key, or -1 if key is not found.
+func (o *keyOrder) indexOf(key string) int {
+	b, ok := o.where[key]
+	if !ok {
+		return -1
+	}
+	for i, k := range b.keys {
+		if k == key {
+			return b.first + i
+		}
+	}
+	return -1
+}
+
+// insert inserts key at position pos, which must not already contain key.
+// Panics if pos < 0 or pos > o.n.
+func (o *keyOrder) insert(pos int, key string) {
+	var b *keyBlock
+	var i int
+	if pos == o.n {
+		if len(o.blocks) == 0 {
+			o.blocks = append(o.blocks, newKeyBlock())
+		}
+		b = o.blocks[len(o.blocks)-1]
+		i = len(b.keys)
+	} else {
+		b, i = o.find(pos)
+	}
+
+	b.keys = append(b.keys, "")
+	copy(b.keys[i+1:], b.keys[i:])
+	b.keys[i] = key
+	o.where[key] = b
+	o.n++
+
+	if len(b.keys) > 2*keyBlockSize {
+		o.split(b)
+	}
+	o.renumber(b.index)
+}
+
+// split moves the second half of the keys in b to a new block after b.
+func (o *keyOrder) split(b *keyBlock) {
+	nb := newKeyBlock()
+	nb.keys = append(nb.keys, b.keys[keyBlockSize:]...)
+	for i := keyBlockSize; i < len(b.keys); i++ {
+		b.keys[i] = ""
+	}
+	b.keys = b.keys[:keyBlockSize]
+	for _, key := range nb.keys {
+		o.where[key] = nb
+	}
+
+	o.blocks = append(o.blocks, nil)
+	copy(o.blocks[b.index+2:], o.blocks[b.index+1:])
+	o.blocks[b.index+1] = nb
+}
+
+// delete deletes the key at position pos, and returns it. Panics if pos < 0
+// or pos >= o.n.
+func (o *keyOrder) delete(pos int) string {
+	b, i := o.find(pos)
+	key := b.keys[i]
+	copy(b.keys[i:], b.keys[i+1:])
+	b.keys[len(b.keys)-1] = ""
+	b.keys = b.keys[:len(b.keys)-1]
+	delete(o.where, key)
+	o.n--
+
+	if len(b.keys) == 0 {
+		copy(o.blocks[b.index:], o.blocks[b.index+1:])
+		o.blocks[len(o.blocks)-1] = nil
+		o.blocks = o.blocks[:len(o.blocks)-1]
+	}
+	o.renumber(b.index)
+	return key
+}
+
+// rename replaces oldKey with newKey, which must not already exist in o.
+func (o *keyOrder) rename(oldKey, newKey string) {
+	b := o.where[oldKey]
+	for i, k := range b.keys {
+		if k == oldKey {
+			b.keys[i] = newKey
+			break
+		}
+	}
+	delete(o.where, oldKey)
+	o.where[newKey] = b
+}
+
+// renumber updates index and first of the blocks starting at from.
+func (o *keyOrder) renumber(from int) {
+	first := 0
+	if from > 0 {
+		prev := o.blocks[from-1]
+		first = prev.first + len(prev.keys)
+	}
+	for i := from; i < len(o.blocks); i++ {
+		b := o.blocks[i]
+		b.index = i
+		b.first = first
+		first += len(b.keys)
+	}
+}
+
+// orderedKeys indexes the positions of the keys of an OrderedMap or
+// OrderedMapOf, whose exported Keys slice is passed to each method. The methods
+// always keep Keys up to date. order is only an index of Keys, and positions
+// found in it are verified against Keys before they are used, because Keys can
+// be rearranged directly.
+type orderedKeys struct {
+	// keys is the address of the Keys slice that order was built from. It
+	// differs from the address passed to the methods if the OrderedMap has
+	// been copied by value, in which case the copy must neither share order
+	// nor edit the array of Keys that it shares with the original.
+	keys  *[]string
+	order *keyOrder
+}
+
+// own makes o the index of *keys, copying *keys if it belongs to another copy
+// of the map, and drops order if keys have been added to or removed from *keys
+// directly.
+func (o *orderedKeys) own(keys *[]string) {
+	if o.keys != keys {
+		*keys = append([]string(nil), *keys...)
+		o.keys = keys
+		o.order = nil
+	} else if o.order != nil && o.order.n != len(*keys) {
+		o.order = nil
+	}
+}
+
+// reset drops order, after keys have been rearranged or removed directly.
+func (o *orderedKeys) reset() {
+	o.order = nil
+}
+
+// indexOf returns the position of key, or -1 if key is not found.
+func (o *orderedKeys) indexOf(keys *[]string, key string) int {
+	o.own(keys)
+	if o.order != nil {
+		if index := o.order.indexOf(key); index >= 0 && (*keys)[index] == key {
+			return index
+		}
+	}
+
+	// Keys has been rearranged since o.order was built.
+	o.order = newKeyOrder(*keys)
+	return o.order.indexOf(key)
+}
+
+// insert inserts the new key at the specified index. Panics if index < 0 or
+// index > len(*keys).
+func (o *orderedKeys) insert(keys *[]string, index int, key string) {
+	o.own(keys)
+	if o.order != nil {
+		o.order.insert(index, key)
+	}
+	if index == len(*keys) {
+		*keys = append(*keys, key)
+	} else {
+		*keys = append((*keys)[:index+1], (*keys)[index:]...)
+		(*keys)[index] = key
+	}
+}
+
+// delete deletes the key at the specified index, and returns it. Panics if
+// index < 0 or index >= len(*keys).
+func (o *orderedKeys) delete(keys *[]string, index int) string {
+	o.own(keys)
+	key := (*keys)[index]
+	if o.order != nil && o.order.at(index) == key {
+		o.order.delete(index)
+	} else {
+		o.order = nil
+	}
+	copy((*keys)[index:], (*keys)[index+1:])
+	(*keys)[len(*keys)-1] = ""
+	*keys = (*keys)[:len(*keys)-1]
+	return key
+}
+
+// deleteKey deletes key, and returns false if key is not found.
+func (o *orderedKeys) deleteKey(keys *[]string, key string) bool {
+	index := o.indexOf(keys, key)
+	if index < 0 {
+		return false
+	}
+	o.delete(keys, index)
+	return true
+}
+
+// rename replaces oldKey, found at the specified index, with newKey.
+func (o *orderedKeys) rename(keys *[]string, index int, oldKey, newKey string) {
+	o.own(keys)
+	(*keys)[index] = newKey
+	if o.order != nil && o.order.at(index) == oldKey {
+		o.order.rename(oldKey, newKey)
+	} else {
+		o.order = nil
+	}
+}
+
+// move moves key to the position newIndex. Returns false if key is not found.
+// Panics if newIndex < 0 or newIndex >= len(*keys).
+func (o *orderedKeys) move(keys *[]string, key string, newIndex int) bool {
+	index := o.indexOf(keys, key)
+	if index < 0 {
+		return false
+	}
+	if newIndex < 0 || newIndex >= len(*keys) {
+		panic("hjson: index out of range")
+	}
+	o.delete(keys, index)
+	o.insert(keys, newIndex, key)
+	return true
+}
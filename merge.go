@@ -0,0 +1,185 @@
+package hjson
+
+// ArrayMergeStrategy specifies how arrays are merged by Node.Merge() and
+// OrderedMap.Merge().
+type ArrayMergeStrategy int
+
+const (
+	// ArrayReplace replaces the array in the base with the array from the
+	// patch, as specified by JSON Merge Patch (RFC 7396).
+	ArrayReplace ArrayMergeStrategy = iota
+	// ArrayAppend appends the elements from the array in the patch to the
+	// array in the base.
+	ArrayAppend
+	// ArrayMergeByKey merges each object in the array from the patch with the
+	// object in the array in the base that has the same value for the key
+	// MergeOptions.MergeKey. Elements in the patch without a matching element in
+	// the base are appended.
+	ArrayMergeByKey
+)
+
+// MergeOptions defines options for Node.Merge() and OrderedMap.Merge().
+type MergeOptions struct {
+	// Arrays specifies how an array in the patch is merged with an array in the
+	// base.
+	Arrays ArrayMergeStrategy
+	// MergeKey is the key used to match objects in arrays when Arrays is
+	// ArrayMergeByKey.
+	MergeKey string
+}
+
+// DefaultMergeOptions returns the default merge options, which follow JSON
+// Merge Patch (RFC 7396).
+func DefaultMergeOptions() MergeOptions {
+	return MergeOptions{
+		Arrays: ArrayReplace,
+	}
+}
+
+// Merge merges patch into the Node tree c, using the semantics of JSON Merge
+// Patch (RFC 7396): if patch contains an object, each key in the patch object
+// is merged recursively into the object in c, and a key with the value null is
+// deleted from c. Any other value in patch replaces the value in c, except for
+// arrays if options.Arrays is not ArrayReplace.
+//
+// Keys that exist in c keep their position, new keys are appended to the end
+// of their object. Each value gets its comments from the Node (in c or in
+// patch) that supplied it. Objects and arrays that are merged keep their
+// comments from c. Values from patch are copied, so that c does not share any
+// Node with patch.
+func (c *Node) Merge(patch *Node, options MergeOptions) {
+	if c == nil || patch == nil {
+		return
+	}
+	value, fromPatch := mergeValue(c.Value, patch.Value, options)
+	c.Value = value
+	if fromPatch {
+		c.Cm = patch.Cm
+	}
+}
+
+// Merge merges patch into c, in the same way as Node.Merge(). The elements in
+// c and patch can be of the type *Node or plain values.
+func (c *OrderedMap) Merge(patch *OrderedMap, options MergeOptions) {
+	if c == nil || patch == nil {
+		return
+	}
+	mergeObject(c, patch, options)
+}
+
+// mergeValue returns the result of merging patch into base. base is modified
+// if it is an object or an array that is merged with patch. The second
+// returned value is true if the result was supplied by patch rather than
+// base.
+func mergeValue(base, patch interface{}, options MergeOptions) (interface{}, bool) {
+	switch pv := patch.(type) {
+	case *OrderedMap:
+		if om, ok := base.(*OrderedMap); ok {
+			mergeObject(om, pv, options)
+			return om, false
+		}
+		om := NewOrderedMap()
+		mergeObject(om, pv, options)
+		return om, true
+
+	case []interface{}:
+		arr, ok := base.([]interface{})
+		if !ok {
+			break
+		}
+		switch options.Arrays {
+		case ArrayAppend:
+			for _, elem := range pv {
+				arr = append(arr, cloneValue(elem))
+			}
+			return arr, false
+		case ArrayMergeByKey:
+			return mergeArrayByKey(arr, pv, options), false
+		}
+	}
+
+	return cloneValue(patch), true
+}
+
+// mergeElem returns the result of merging the element patch into the element
+// base, keeping the representation (*Node or plain value) of base. If exists is
+// false there is no base element, and the representation of patch is used.
+func mergeElem(base, patch interface{}, exists bool, options MergeOptions) interface{} {
+	pNode, pIsNode := patch.(*Node)
+	if pIsNode {
+		patch = pNode.Value
+	}
+
+	bNode, bIsNode := base.(*Node)
+	if !bIsNode {
+		value, _ := mergeValue(base, patch, options)
+		if !exists && pIsNode {
+			return &Node{Value: value, Cm: pNode.Cm}
+		}
+		return value
+	}
+
+	value, fromPatch := mergeValue(bNode.Value, patch, options)
+	bNode.Value = value
+	if fromPatch && pIsNode {
+		bNode.Cm = pNode.Cm
+	}
+	return bNode
+}
+
+// mergeObject merges the elements from patch into base.
+func mergeObject(base, patch *OrderedMap, options MergeOptions) {
+	for _, key := range patch.Keys {
+		elem := patch.Map[key]
+		if isNullElem(elem) {
+			base.DeleteKey(key)
+			continue
+		}
+		baseElem, exists := base.Map[key]
+		base.Set(key, mergeElem(baseElem, elem, exists, options))
+	}
+}
+
+// mergeArrayByKey merges each object in patch with the object in base that has
+// the same value for options.MergeKey. Other elements in patch are appended.
+func mergeArrayByKey(base, patch []interface{}, options MergeOptions) []interface{} {
+	for _, elem := range patch {
+		index := -1
+		if key, ok := mergeKeyValue(elem, options.MergeKey); ok {
+			for i, baseElem := range base {
+				if baseKey, ok := mergeKeyValue(baseElem, options.MergeKey); ok &&
+					valuesEqual(baseKey, key) {
+					index = i
+					break
+				}
+			}
+		}
+		if index >= 0 {
+			base[index] = mergeElem(base[index], elem, true, options)
+		} else {
+			base = append(base, cloneValue(elem))
+		}
+	}
+	return base
+}
+
+// mergeKeyValue returns the value for key, if elem is an object containing
+// key.
+func mergeKeyValue(elem interface{}, key string) (interface{}, bool) {
+	if node, ok := elem.(*Node); ok {
+		elem = node.Value
+	}
+	om, ok := elem.(*OrderedMap)
+	if !ok {
+		return nil, false
+	}
+	value, ok := om.Map[key]
+	return value, ok
+}
+
+func isNullElem(elem interface{}) bool {
+	if node, ok := elem.(*Node); ok {
+		return node.Value == nil
+	}
+	return elem == nil
+}
@@ -0,0 +1,157 @@
+package hjson
+
+import (
+	"testing"
+)
+
+func TestNodeMerge(t *testing.T) {
+	base := unmarshalNode(t, `# base config
+{
+  # service name
+  name: app
+  # log settings
+  log: {
+    level: info # default level
+    file: /var/log/app.log
+  }
+  servers: [
+    {name: "a", port: 1}
+  ]
+  debug: false
+}`)
+	patch := unmarshalNode(t, `{
+  log: {
+    # more output
+    level: debug
+    file: null
+  }
+  servers: [
+    {name: "b", port: 2}
+  ]
+  # new key
+  extra: {
+    x: 1
+    y: null
+  }
+  missing: null
+}`)
+
+	base.Merge(patch, DefaultMergeOptions())
+
+	expected := `# base config
+{
+  # service name
+  name: app
+  # log settings
+  log: {
+    # more output
+    level: debug
+  }
+  servers: [
+    {
+      name: b
+      port: 2
+    }
+  ]
+  debug: false
+  # new key
+  extra: {
+    x: 1
+  }
+}`
+	b, err := Marshal(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, string(b))
+	}
+
+	// The merged tree must not share any Node with the patch.
+	patch.NK("servers").NI(0).NK("port").Value = 3.0
+	if base.NK("servers").NI(0).NK("port").Value != 2.0 {
+		t.Error("Node shared with the patch")
+	}
+}
+
+func TestNodeMergeArrays(t *testing.T) {
+	baseText := `{
+  servers: [
+    {name: "a", port: 1}
+    {name: "b", port: 2}
+  ]
+}`
+	patchText := `{
+  servers: [
+    {name: "b", port: 3, tls: true}
+    {name: "c", port: 4}
+    5
+  ]
+}`
+
+	testCases := []struct {
+		options  MergeOptions
+		expected string
+	}{
+		{
+			MergeOptions{Arrays: ArrayAppend},
+			`[{"name":"a","port":1},{"name":"b","port":2},{"name":"b","port":3,"tls":true},{"name":"c","port":4},5]`,
+		},
+		{
+			MergeOptions{Arrays: ArrayMergeByKey, MergeKey: "name"},
+			`[{"name":"a","port":1},{"name":"b","port":3,"tls":true},{"name":"c","port":4},5]`,
+		},
+		{
+			MergeOptions{Arrays: ArrayReplace},
+			`[{"name":"b","port":3,"tls":true},{"name":"c","port":4},5]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		base := unmarshalNode(t, baseText)
+		base.Merge(unmarshalNode(t, patchText), tc.options)
+		b, err := base.NK("servers").MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("%v: Expected:\n%s\n\nGot:\n%s", tc.options, tc.expected, string(b))
+		}
+	}
+}
+
+func TestOrderedMapMerge(t *testing.T) {
+	// The examples from RFC 7396.
+	testCases := []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tc := range testCases {
+		var target, patch OrderedMap
+		if err := Unmarshal([]byte(tc.target), &target); err != nil {
+			t.Fatal(err)
+		}
+		if err := Unmarshal([]byte(tc.patch), &patch); err != nil {
+			t.Fatal(err)
+		}
+		target.Merge(&patch, DefaultMergeOptions())
+		b, err := target.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("%s + %s: Expected %s, got %s", tc.target, tc.patch, tc.expected, string(b))
+		}
+	}
+}
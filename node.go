@@ -6,6 +6,12 @@ import (
 	"reflect"
 )
 
+// Comments holds the comments and whitespace around a value in an Hjson
+// document. The fields can be set directly, in the format described for each
+// field, or using SetText() which generates the comment markers, line feeds
+// and indentation. Comments also records which fields were set by SetText(),
+// so use keyed fields in Comments literals, and compare Nodes with Equal()
+// rather than == or reflect.DeepEqual().
 type Comments struct {
 	// Comment/whitespace on line(s) before the value, and before the value on
 	// the same line. If not empty, is expected to end with a line feed +
@@ -29,6 +35,38 @@ type Comments struct {
 	// after those lines, or to `InsideLast` on the slice/map if the lines
 	// containing comments appear after the last element inside a slice/map.
 	After string
+
+	// Bit mask of the positions where the comments were set by SetText(), and
+	// are indented when written by Marshal().
+	text uint8
+}
+
+// Position is a location in the Hjson input.
+type Position struct {
+	Offset int // Byte offset, starting at 0.
+	Line   int // Line number, starting at 1.
+	Column int // Column number (byte count on the line), starting at 1.
+}
+
+// String returns the position as "line:column".
+func (pos Position) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// Span is a range in the Hjson input, from Start up to (but not including)
+// End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// NodePos contains the positions in the Hjson input of a value and its key.
+type NodePos struct {
+	// Key is the position of the key, if the value is an element in an object.
+	// Otherwise Key is the zero Span.
+	Key Span
+	// Value is the position of the value, excluding any comments.
+	Value Span
 }
 
 // Node must be used as destination for Unmarshal() or UnmarshalWithOptions()
@@ -70,6 +108,9 @@ type Comments struct {
 type Node struct {
 	Value interface{}
 	Cm    Comments
+	// Pos is only set by Unmarshal() or UnmarshalWithOptions() if the option
+	// DecoderOptions.RecordPositions is true. Pos is not used when marshalling.
+	Pos *NodePos
 }
 
 // Len returns the length of the value wrapped by this Node, if the value is of
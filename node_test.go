@@ -22,6 +22,16 @@ func verifyNodeContent(t *testing.T, node *Node, txtExpected string) {
 	compareStrings(t, bOut, txtExpected)
 }
 
+// unmarshalNode returns the Node tree for text, and stops the test if text
+// cannot be unmarshalled.
+func unmarshalNode(t *testing.T, text string) *Node {
+	var node Node
+	if err := Unmarshal([]byte(text), &node); err != nil {
+		t.Fatal(err)
+	}
+	return &node
+}
+
 func TestNode1(t *testing.T) {
 	txt := `b: 1
 a: 2`
@@ -586,3 +596,55 @@ func TestNodeSliceInsertDelete(t *testing.T) {
   1
 ]`)
 }
+
+func TestNodePositions(t *testing.T) {
+	txt := `# header
+a: 1  # comment
+b: {
+  c: quoteless text  
+  "d": [true, "x"]
+}
+`
+	decOpt := DefaultDecoderOptions()
+	decOpt.RecordPositions = true
+	var node Node
+	if err := UnmarshalWithOptions([]byte(txt), &node, decOpt); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		node       *Node
+		key, value string // "line:column-line:column"
+	}{
+		{&node, "0:0-0:0", "2:1-6:2"},
+		{node.NK("a"), "2:1-2:2", "2:4-2:5"},
+		{node.NK("b"), "3:1-3:2", "3:4-6:2"},
+		{node.NK("b").NK("c"), "4:3-4:4", "4:6-4:20"},
+		{node.NK("b").NK("d"), "5:3-5:6", "5:8-5:19"},
+		{node.NK("b").NK("d").NI(0), "0:0-0:0", "5:9-5:13"},
+		{node.NK("b").NK("d").NI(1), "0:0-0:0", "5:15-5:18"},
+	}
+	for i, tc := range testCases {
+		if tc.node.Pos == nil {
+			t.Errorf("%d: Pos is nil", i)
+			continue
+		}
+		key := tc.node.Pos.Key.Start.String() + "-" + tc.node.Pos.Key.End.String()
+		value := tc.node.Pos.Value.Start.String() + "-" + tc.node.Pos.Value.End.String()
+		if key != tc.key || value != tc.value {
+			t.Errorf("%d: Expected key %s value %s, got key %s value %s", i, tc.key, tc.value, key, value)
+		}
+	}
+
+	pos := node.NK("b").NK("c").Pos.Value
+	if string(txt[pos.Start.Offset:pos.End.Offset]) != "quoteless text" {
+		t.Errorf("Unexpected value span: %#v", pos)
+	}
+
+	if err := Unmarshal([]byte(txt), &node); err != nil {
+		t.Fatal(err)
+	}
+	if node.Pos != nil || node.NK("a").Pos != nil {
+		t.Error("Pos should not be set unless RecordPositions is true")
+	}
+}
@@ -3,19 +3,21 @@ package hjson
 import (
 	"bytes"
 	"encoding/json"
+	"sort"
 )
 
 // OrderedMap wraps a map and a slice containing all of the keys from the map,
 // so that the order of the keys can be specified. The Keys slice can be sorted
 // or rearranged like any other slice, but do not add or remove keys manually
-// on it. Use OrderedMap.Insert(), OrderedMap.Set(), OrderedMap.DeleteIndex() or
-// OrderedMap.DeleteKey() instead.
+// on it. Use OrderedMap.Insert(), OrderedMap.Set(), OrderedMap.DeleteIndex(),
+// OrderedMap.DeleteKey() or OrderedMap.DeleteKeys() instead.
 //
 // Example of how to iterate through the elements of an OrderedMap in order:
 //
-//	for _, key := range om.Keys {
-//	  fmt.Printf("%v\n", om.Map[key])
-//	}
+//	om.Range(func(index int, key string, value interface{}) bool {
+//	  fmt.Printf("%v\n", value)
+//	  return true
+//	})
 //
 // Always use the functions Insert() or Set() instead of setting values
 // directly on OrderedMap.Map, because any new keys must also be added to
@@ -25,6 +27,8 @@ import (
 type OrderedMap struct {
 	Keys []string
 	Map  map[string]interface{}
+
+	order orderedKeys
 }
 
 // KeyValue is only used as input to NewOrderedMapFromSlice().
@@ -77,6 +81,14 @@ func (c *OrderedMap) AtKey(key string) (interface{}, bool) {
 	return ret, ok
 }
 
+// IndexOf returns the position of key in c.Keys, or -1 if key is not found.
+func (c *OrderedMap) IndexOf(key string) int {
+	if _, ok := c.Map[key]; !ok {
+		return -1
+	}
+	return c.order.indexOf(&c.Keys, key)
+}
+
 // Insert inserts a new key/value pair at the specified index. Panics if
 // index < 0 or index > c.Len(). If the key already exists in the OrderedMap,
 // the new value is set but the position of the key is not changed. Returns
@@ -88,12 +100,7 @@ func (c *OrderedMap) Insert(index int, key string, value interface{}) (interface
 	if exists {
 		return oldValue, true
 	}
-	if index == len(c.Keys) {
-		c.Keys = append(c.Keys, key)
-	} else {
-		c.Keys = append(c.Keys[:index+1], c.Keys[index:]...)
-		c.Keys[index] = key
-	}
+	c.order.insert(&c.Keys, index, key)
 	return nil, false
 }
 
@@ -103,16 +110,15 @@ func (c *OrderedMap) Insert(index int, key string, value interface{}) (interface
 // position of the key is not changed. Returns the old value and true if the
 // key already exists in the OrderedMap, nil and false otherwise.
 func (c *OrderedMap) Set(key string, value interface{}) (interface{}, bool) {
-	return c.Insert(len(c.Keys), key, value)
+	return c.Insert(c.Len(), key, value)
 }
 
 // DeleteIndex deletes the key/value pair found at the specified index.
 // Returns the deleted key and value. Panics if index < 0 or index >= c.Len().
 func (c *OrderedMap) DeleteIndex(index int) (string, interface{}) {
-	key := c.Keys[index]
+	key := c.order.delete(&c.Keys, index)
 	value := c.Map[key]
 	delete(c.Map, key)
-	c.Keys = append(c.Keys[:index], c.Keys[index+1:]...)
 	return key, value
 }
 
@@ -120,13 +126,131 @@ func (c *OrderedMap) DeleteIndex(index int) (string, interface{}) {
 // Returns the deleted value and true if the key was found, nil and false
 // otherwise.
 func (c *OrderedMap) DeleteKey(key string) (interface{}, bool) {
-	for index, ck := range c.Keys {
-		if ck == key {
-			_, value := c.DeleteIndex(index)
-			return value, true
+	value, ok := c.Map[key]
+	if !ok || !c.order.deleteKey(&c.Keys, key) {
+		return nil, false
+	}
+	delete(c.Map, key)
+	return value, true
+}
+
+// DeleteKeys deletes the key/value pairs with the specified keys, if found.
+// Returns the number of deleted key/value pairs. The remaining keys are only
+// moved once, however many keys are deleted.
+func (c *OrderedMap) DeleteKeys(keys ...string) int {
+	deleted := map[string]struct{}{}
+	for _, key := range keys {
+		if _, ok := c.Map[key]; ok {
+			delete(c.Map, key)
+			deleted[key] = struct{}{}
 		}
 	}
-	return nil, false
+	if len(deleted) == 0 {
+		return 0
+	}
+
+	c.removeKeys(func(key string) bool {
+		_, ok := deleted[key]
+		return ok
+	})
+	return len(deleted)
+}
+
+// Filter calls keep for each key/value pair in order, and deletes the key/value
+// pairs for which keep returns false. Returns the number of deleted key/value
+// pairs.
+func (c *OrderedMap) Filter(keep func(key string, value interface{}) bool) int {
+	n := c.Len()
+	c.removeKeys(func(key string) bool {
+		if keep(key, c.Map[key]) {
+			return false
+		}
+		delete(c.Map, key)
+		return true
+	})
+	return n - c.Len()
+}
+
+// removeKeys removes the keys for which remove returns true from c.Keys, only
+// moving the remaining keys once.
+func (c *OrderedMap) removeKeys(remove func(key string) bool) {
+	c.order.own(&c.Keys)
+	keys := c.Keys
+	remaining := keys[:0]
+	for _, key := range keys {
+		if !remove(key) {
+			remaining = append(remaining, key)
+		}
+	}
+	// Clear the unused part of the slice so that the removed keys can be
+	// garbage collected.
+	for i := len(remaining); i < len(keys); i++ {
+		keys[i] = ""
+	}
+	c.Keys = remaining
+	c.order.reset()
+}
+
+// Range calls fn for each key/value pair in order, together with the index of
+// the key/value pair, until fn returns false. If keys are added to or deleted
+// from c during the iteration, Range continues with the keys that c contained
+// when Range was called, skipping keys that have been deleted.
+func (c *OrderedMap) Range(fn func(index int, key string, value interface{}) bool) {
+	keys := append([]string(nil), c.Keys...)
+	for index, key := range keys {
+		value, ok := c.Map[key]
+		if ok && !fn(index, key, value) {
+			return
+		}
+	}
+}
+
+// Rename changes the key oldKey to newKey, keeping the position and the value
+// of oldKey. Returns true if oldKey was renamed (or if oldKey equals newKey
+// and exists in c). Returns false if oldKey does not exist or if newKey
+// already exists in c.
+func (c *OrderedMap) Rename(oldKey, newKey string) bool {
+	index := c.IndexOf(oldKey)
+	if index < 0 {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if _, exists := c.Map[newKey]; exists {
+		return false
+	}
+
+	c.order.rename(&c.Keys, index, oldKey, newKey)
+	c.Map[newKey] = c.Map[oldKey]
+	delete(c.Map, oldKey)
+	return true
+}
+
+// Move moves key to the position newIndex, shifting the keys between the old
+// and the new position. Returns false if key does not exist in c. Panics if
+// newIndex < 0 or newIndex >= c.Len().
+func (c *OrderedMap) Move(key string, newIndex int) bool {
+	if _, ok := c.Map[key]; !ok {
+		return false
+	}
+	return c.order.move(&c.Keys, key, newIndex)
+}
+
+// SortKeys sorts the keys of c using less to compare keys, or in alphabetical
+// order if less is nil. The sort is stable.
+func (c *OrderedMap) SortKeys(less func(a, b string) bool) {
+	if less == nil {
+		less = func(a, b string) bool {
+			return a < b
+		}
+	}
+	c.order.own(&c.Keys)
+	keys := c.Keys
+	sort.SliceStable(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+	c.order.reset()
 }
 
 // MarshalJSON is an implementation of the json.Marshaler interface, enabling
@@ -163,5 +287,6 @@ func (c *OrderedMap) MarshalJSON() ([]byte, error) {
 func (c *OrderedMap) UnmarshalJSON(b []byte) error {
 	c.Keys = nil
 	c.Map = map[string]interface{}{}
+	c.order.reset()
 	return Unmarshal(b, c)
 }
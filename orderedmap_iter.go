@@ -0,0 +1,25 @@
+//go:build go1.23
+// +build go1.23
+
+package hjson
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the key/value pairs in c, in order. It can be
+// used in a for loop:
+//
+//	for key, value := range om.All() {
+//	  fmt.Printf("%s: %v\n", key, value)
+//	}
+//
+// Keys that are added to or deleted from c during the iteration are handled
+// as by OrderedMap.Range().
+func (c *OrderedMap) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		c.Range(func(_ int, key string, value interface{}) bool {
+			return yield(key, value)
+		})
+	}
+}
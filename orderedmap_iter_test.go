@@ -0,0 +1,32 @@
+//go:build go1.23
+// +build go1.23
+
+package hjson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMapAll(t *testing.T) {
+	om := NewOrderedMapFromSlice([]KeyValue{
+		{"B", "first"},
+		{"A", 2},
+		{"C", 3},
+	})
+
+	var keys []string
+	var values []interface{}
+	for key, value := range om.All() {
+		if key == "C" {
+			break
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+		om.DeleteKey("A")
+	}
+
+	if !reflect.DeepEqual(keys, []string{"B"}) || !reflect.DeepEqual(values, []interface{}{"first"}) {
+		t.Errorf("Unexpected keys %v and values %v", keys, values)
+	}
+}
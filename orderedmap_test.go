@@ -2,7 +2,10 @@ package hjson
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"testing"
 )
 
@@ -91,6 +94,248 @@ func TestInsert(t *testing.T) {
 	verifyContent(t, om, `{}`)
 }
 
+func TestDeleteKeyIndex(t *testing.T) {
+	om := NewOrderedMap()
+	for i := 0; i < 10; i++ {
+		om.Set(strconv.Itoa(i), i)
+	}
+
+	for _, key := range []string{"5", "3", "8"} {
+		if _, found := om.DeleteKey(key); !found {
+			t.Errorf("DeleteKey returned false for existing key %s.", key)
+		}
+	}
+	verifyContent(t, om, `{"0":0,"1":1,"2":2,"4":4,"6":6,"7":7,"9":9}`)
+
+	om.Insert(1, "x", "x")
+	om.Set("y", "y")
+	if _, found := om.DeleteKey("7"); !found {
+		t.Errorf("DeleteKey returned false for existing key 7.")
+	}
+	verifyContent(t, om, `{"0":0,"x":"x","1":1,"2":2,"4":4,"6":6,"9":9,"y":"y"}`)
+
+	// The Keys slice may be rearranged directly.
+	sort.Sort(sort.Reverse(sort.StringSlice(om.Keys)))
+	for _, key := range []string{"x", "0", "4", "5"} {
+		_, found := om.DeleteKey(key)
+		if found != (key != "5") {
+			t.Errorf("DeleteKey returned %v for key %s.", found, key)
+		}
+	}
+	verifyContent(t, om, `{"y":"y","9":9,"6":6,"2":2,"1":1}`)
+}
+
+func TestDeleteKeys(t *testing.T) {
+	om := NewOrderedMapFromSlice([]KeyValue{
+		{"B", "first"},
+		{"A", 2},
+		{"C", 3},
+		{"D", 4},
+	})
+
+	if n := om.DeleteKeys("C", "XYZ", "B", "C"); n != 2 {
+		t.Errorf("Expected 2 deleted keys, got %d", n)
+	}
+	verifyContent(t, om, `{"A":2,"D":4}`)
+
+	if n := om.DeleteKeys(); n != 0 {
+		t.Errorf("Expected 0 deleted keys, got %d", n)
+	}
+	om.Set("E", 5)
+	if _, found := om.DeleteKey("D"); !found {
+		t.Errorf("DeleteKey returned false for existing key.")
+	}
+	verifyContent(t, om, `{"A":2,"E":5}`)
+}
+
+func TestOrderedMapLargeEdits(t *testing.T) {
+	// Enough keys to split the internal order into several blocks.
+	om := NewOrderedMap()
+	var expected []string
+	for i := 0; i < 3000; i++ {
+		key := strconv.Itoa(i)
+		om.Set(key, i)
+		expected = append(expected, key)
+	}
+
+	check := func(step int) {
+		if om.Len() != len(expected) {
+			t.Fatalf("Step %d: expected length %d, got %d", step, len(expected), om.Len())
+		}
+		for i, key := range expected {
+			if om.IndexOf(key) != i || om.AtIndex(i) != om.Map[key] {
+				t.Fatalf("Step %d: key %s not found at index %d", step, key, i)
+			}
+		}
+	}
+
+	for step := 0; step < 2000; step++ {
+		index := (step * 7919) % len(expected)
+		switch step % 4 {
+		case 0, 1:
+			key := "n" + strconv.Itoa(step)
+			om.Insert(index, key, step)
+			expected = append(expected[:index+1], expected[index:]...)
+			expected[index] = key
+		case 2:
+			om.DeleteKey(expected[index])
+			expected = append(expected[:index], expected[index+1:]...)
+		case 3:
+			om.DeleteIndex(0)
+			expected = expected[1:]
+		}
+		if step%250 == 0 {
+			check(step)
+		}
+	}
+	check(-1)
+	if !reflect.DeepEqual(om.Keys, expected) {
+		t.Fatal("Keys is not up to date.")
+	}
+
+	// Keys can be rearranged directly between the edits.
+	om.Keys[0], om.Keys[1] = om.Keys[1], om.Keys[0]
+	expected[0], expected[1] = expected[1], expected[0]
+	om.DeleteKey(expected[2])
+	expected = append(expected[:2], expected[3:]...)
+	check(-2)
+}
+
+func TestOrderedMapKeysUpToDate(t *testing.T) {
+	om := NewOrderedMapFromSlice([]KeyValue{
+		{"a", 1},
+		{"b", 2},
+	})
+	om.Insert(0, "z", 3)
+	if !reflect.DeepEqual(om.Keys, []string{"z", "a", "b"}) {
+		t.Errorf("Unexpected Keys after Insert: %v", om.Keys)
+	}
+	om.DeleteKey("a")
+	if !reflect.DeepEqual(om.Keys, []string{"z", "b"}) {
+		t.Errorf("Unexpected Keys after DeleteKey: %v", om.Keys)
+	}
+
+	// Keys rearranged directly must be used by Marshal and by later edits.
+	sort.Strings(om.Keys)
+	out, err := Marshal(om)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "{\n  b: 2\n  z: 3\n}" {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+	om.Insert(1, "c", 4)
+	if !reflect.DeepEqual(om.Keys, []string{"b", "c", "z"}) || om.IndexOf("z") != 2 {
+		t.Errorf("Unexpected Keys after sorting and Insert: %v", om.Keys)
+	}
+}
+
+func TestOrderedMapValueCopy(t *testing.T) {
+	om := NewOrderedMap()
+	for _, key := range []string{"a", "b", "c"} {
+		om.Set(key, key)
+	}
+	om.IndexOf("b")
+
+	// The copy shares Map with the original, but not the order of the keys.
+	cp := *om
+	cp.Insert(0, "z", "z")
+	if !reflect.DeepEqual(cp.Keys, []string{"z", "a", "b", "c"}) {
+		t.Errorf("Unexpected Keys in the copy: %v", cp.Keys)
+	}
+	if om.Len() != 3 || !reflect.DeepEqual(om.Keys, []string{"a", "b", "c"}) {
+		t.Errorf("The original was changed by editing the copy: %v", om.Keys)
+	}
+	for i, key := range om.Keys {
+		if om.IndexOf(key) != i {
+			t.Errorf("Expected %s at index %d in the original, got %d", key, i, om.IndexOf(key))
+		}
+	}
+
+	om.DeleteKey("a")
+	if !reflect.DeepEqual(om.Keys, []string{"b", "c"}) ||
+		!reflect.DeepEqual(cp.Keys, []string{"z", "a", "b", "c"}) {
+		t.Errorf("Unexpected Keys after editing the original: %v, %v", om.Keys, cp.Keys)
+	}
+}
+
+func TestOrderedMapRange(t *testing.T) {
+	om := NewOrderedMapFromSlice([]KeyValue{
+		{"B", "first"},
+		{"A", 2},
+		{"C", 3},
+		{"D", 4},
+	})
+
+	var visited []string
+	om.Range(func(index int, key string, value interface{}) bool {
+		visited = append(visited, fmt.Sprintf("%d:%s=%v", index, key, value))
+		if key == "B" {
+			om.DeleteKey("A")
+			om.Set("E", 5)
+		}
+		return key != "C"
+	})
+	if !reflect.DeepEqual(visited, []string{"0:B=first", "2:C=3"}) {
+		t.Errorf("Unexpected iteration: %v", visited)
+	}
+
+	if i := om.IndexOf("D"); i != 2 {
+		t.Errorf("Expected index 2 for key D, got %d", i)
+	}
+	if i := om.IndexOf("XYZ"); i != -1 {
+		t.Errorf("Expected index -1 for key XYZ, got %d", i)
+	}
+}
+
+func TestOrderedMapRenameMove(t *testing.T) {
+	om := NewOrderedMapFromSlice([]KeyValue{
+		{"B", "first"},
+		{"A", 2},
+		{"C", 3},
+		{"D", 4},
+	})
+
+	if !om.Rename("A", "X") || !om.Rename("X", "X") {
+		t.Error("Rename returned false for existing key")
+	}
+	if om.Rename("XYZ", "Y") || om.Rename("X", "B") {
+		t.Error("Rename returned true for non-existing key or existing new key")
+	}
+	verifyContent(t, om, `{"B":"first","X":2,"C":3,"D":4}`)
+	if i := om.IndexOf("X"); i != 1 {
+		t.Errorf("Expected index 1 for key X, got %d", i)
+	}
+
+	if !om.Move("D", 0) || !om.Move("B", 3) || !om.Move("X", 1) {
+		t.Error("Move returned false for existing key")
+	}
+	if om.Move("A", 0) {
+		t.Error("Move returned true for non-existing key")
+	}
+	verifyContent(t, om, `{"D":4,"X":2,"C":3,"B":"first"}`)
+	if i := om.IndexOf("B"); i != 3 {
+		t.Errorf("Expected index 3 for key B, got %d", i)
+	}
+
+	om.SortKeys(nil)
+	verifyContent(t, om, `{"B":"first","C":3,"D":4,"X":2}`)
+	om.SortKeys(func(a, b string) bool { return a > b })
+	verifyContent(t, om, `{"X":2,"D":4,"C":3,"B":"first"}`)
+	if _, found := om.DeleteKey("D"); !found || om.IndexOf("C") != 1 {
+		t.Error("Unexpected index after SortKeys")
+	}
+
+	n := om.Filter(func(key string, value interface{}) bool {
+		_, isInt := value.(int)
+		return isInt && key != "C"
+	})
+	if n != 2 {
+		t.Errorf("Expected 2 deleted keys, got %d", n)
+	}
+	verifyContent(t, om, `{"X":2}`)
+}
+
 func TestUnmarshalJSON(t *testing.T) {
 	var om *OrderedMap
 	err := json.Unmarshal([]byte(`{"B":"first","C":3,"sub":{"z":7,"y":8},"A":2}`), &om)
@@ -138,3 +383,74 @@ func TestUnmarshalHJSON_2(t *testing.T) {
 
 	verifyContent(t, &om, `{"B":"first","C":3,"sub":{"z":7,"y":8},"A":2}`)
 }
+
+func newBenchmarkOrderedMap(n int) (*OrderedMap, []string) {
+	om := NewOrderedMap()
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		om.Set(keys[i], i)
+	}
+	return om, keys
+}
+
+func BenchmarkOrderedMapSet(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newBenchmarkOrderedMap(100000)
+	}
+}
+
+// benchmarkOrderedMapDelete deletes 10000 keys from a map of 100000 keys at
+// the index returned by at.
+func benchmarkOrderedMapDelete(b *testing.B, at func(n int) int) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		om, _ := newBenchmarkOrderedMap(100000)
+		b.StartTimer()
+		for om.Len() > 90000 {
+			om.DeleteKey(om.Keys[at(om.Len())])
+		}
+	}
+}
+
+func BenchmarkOrderedMapDeleteFront(b *testing.B) {
+	benchmarkOrderedMapDelete(b, func(n int) int { return 0 })
+}
+
+func BenchmarkOrderedMapDeleteMiddle(b *testing.B) {
+	benchmarkOrderedMapDelete(b, func(n int) int { return n / 2 })
+}
+
+// benchmarkOrderedMapInsert inserts 10000 keys into a map of 100000 keys at
+// the index returned by at.
+func benchmarkOrderedMapInsert(b *testing.B, at func(n int) int) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		om, keys := newBenchmarkOrderedMap(100000)
+		b.StartTimer()
+		for _, key := range keys[:10000] {
+			om.Insert(at(om.Len()), "new"+key, nil)
+		}
+	}
+}
+
+func BenchmarkOrderedMapInsertFront(b *testing.B) {
+	benchmarkOrderedMapInsert(b, func(n int) int { return 0 })
+}
+
+func BenchmarkOrderedMapInsertMiddle(b *testing.B) {
+	benchmarkOrderedMapInsert(b, func(n int) int { return n / 2 })
+}
+
+func BenchmarkOrderedMapDeleteKeys(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		om, keys := newBenchmarkOrderedMap(100000)
+		var toDelete []string
+		for j := 0; j < len(keys); j += 2 {
+			toDelete = append(toDelete, keys[j])
+		}
+		b.StartTimer()
+		om.DeleteKeys(toDelete...)
+	}
+}
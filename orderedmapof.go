@@ -0,0 +1,160 @@
+//go:build go1.18
+// +build go1.18
+
+package hjson
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OrderedMapOf is like OrderedMap, but with values of the type V. It can be
+// used as a destination for Unmarshal(), for example as the type of a struct
+// field, in which case the keys are stored in the order they appear in the
+// Hjson input and each value is decoded into a V. Marshal() writes the keys in
+// the order of Keys.
+//
+// As for OrderedMap, do not add or remove keys manually on Keys or Map. Use
+// OrderedMapOf.Insert(), OrderedMapOf.Set(), OrderedMapOf.DeleteIndex() or
+// OrderedMapOf.DeleteKey() instead.
+type OrderedMapOf[V any] struct {
+	Keys []string
+	Map  map[string]V
+
+	order orderedKeys
+}
+
+// NewOrderedMapOf returns a pointer to a new OrderedMapOf.
+func NewOrderedMapOf[V any]() *OrderedMapOf[V] {
+	return &OrderedMapOf[V]{
+		Keys: nil,
+		Map:  map[string]V{},
+	}
+}
+
+// Len returns the number of values contained in the OrderedMapOf.
+func (c *OrderedMapOf[V]) Len() int {
+	return len(c.Keys)
+}
+
+// AtIndex returns the value found at the specified index. Panics if
+// index < 0 or index >= c.Len().
+func (c *OrderedMapOf[V]) AtIndex(index int) V {
+	return c.Map[c.Keys[index]]
+}
+
+// AtKey returns the value found for the specified key, and true if the value
+// was found. Returns the zero value of V and false if the value was not found.
+func (c *OrderedMapOf[V]) AtKey(key string) (V, bool) {
+	ret, ok := c.Map[key]
+	return ret, ok
+}
+
+// Insert inserts a new key/value pair at the specified index. Panics if
+// index < 0 or index > c.Len(). If the key already exists in the OrderedMapOf,
+// the new value is set but the position of the key is not changed. Returns
+// the old value and true if the key already exists in the OrderedMapOf, the
+// zero value of V and false otherwise.
+func (c *OrderedMapOf[V]) Insert(index int, key string, value V) (V, bool) {
+	if c.Map == nil {
+		c.Map = map[string]V{}
+	}
+	oldValue, exists := c.Map[key]
+	c.Map[key] = value
+	if exists {
+		return oldValue, true
+	}
+	c.order.insert(&c.Keys, index, key)
+	return oldValue, false
+}
+
+// Set sets the specified value for the specified key. If the key does not
+// already exist in the OrderedMapOf it is appended to the end of the
+// OrderedMapOf. If the key already exists in the OrderedMapOf, the new value
+// is set but the position of the key is not changed. Returns the old value and
+// true if the key already exists in the OrderedMapOf, the zero value of V and
+// false otherwise.
+func (c *OrderedMapOf[V]) Set(key string, value V) (V, bool) {
+	return c.Insert(c.Len(), key, value)
+}
+
+// DeleteIndex deletes the key/value pair found at the specified index.
+// Returns the deleted key and value. Panics if index < 0 or index >= c.Len().
+func (c *OrderedMapOf[V]) DeleteIndex(index int) (string, V) {
+	key := c.order.delete(&c.Keys, index)
+	value := c.Map[key]
+	delete(c.Map, key)
+	return key, value
+}
+
+// DeleteKey deletes the key/value pair with the specified key, if found.
+// Returns the deleted value and true if the key was found, the zero value of V
+// and false otherwise.
+func (c *OrderedMapOf[V]) DeleteKey(key string) (V, bool) {
+	value, ok := c.Map[key]
+	if !ok || !c.order.deleteKey(&c.Keys, key) {
+		var zero V
+		return zero, false
+	}
+	delete(c.Map, key)
+	return value, true
+}
+
+// orderedMap returns the key/value pairs of c in an OrderedMap.
+func (c OrderedMapOf[V]) orderedMap() *OrderedMap {
+	om := NewOrderedMap()
+	for _, key := range c.Keys {
+		om.Set(key, c.Map[key])
+	}
+	return om
+}
+
+// MarshalHjson is an implementation of the hjson.Marshaler interface, making
+// Marshal() write the keys in the order of Keys.
+func (c OrderedMapOf[V]) MarshalHjson() (*Node, error) {
+	return &Node{Value: c.orderedMap()}, nil
+}
+
+// MarshalJSON is an implementation of the json.Marshaler interface, enabling
+// hjson.OrderedMapOf to be used as input for json.Marshal().
+func (c OrderedMapOf[V]) MarshalJSON() ([]byte, error) {
+	return c.orderedMap().MarshalJSON()
+}
+
+// UnmarshalJSON is an implementation of the json.Unmarshaler interface,
+// enabling hjson.OrderedMapOf to be used as destination for json.Unmarshal().
+// A JSON null leaves c unchanged.
+func (c *OrderedMapOf[V]) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) == 0 || b[0] != '{' {
+		var value interface{}
+		if err := Unmarshal(b, &value); err != nil {
+			return err
+		}
+		return fmt.Errorf("Cannot unmarshal %s into %v", valueKind(value), reflect.TypeOf(c).Elem())
+	}
+	return Unmarshal(b, c)
+}
+
+// assignObject is an implementation of the objectAssigner interface, so that
+// Unmarshal() decodes each value into a V using the options of p.
+func (c *OrderedMapOf[V]) assignObject(p *hjsonParser, om *OrderedMap) error {
+	res := NewOrderedMapOf[V]()
+	for _, key := range om.Keys {
+		var value V
+		if err := p.assign(om.Map[key], reflect.ValueOf(&value).Elem()); err != nil {
+			return err
+		}
+		res.Set(key, value)
+	}
+	*c = *res
+	return nil
+}
+
+// ElemType is an implementation of the hjson.ElemTyper interface, so that
+// Unmarshal() reads quoteless values as strings if V is a string type.
+func (c *OrderedMapOf[V]) ElemType() reflect.Type {
+	return reflect.TypeOf((*V)(nil)).Elem()
+}
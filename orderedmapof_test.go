@@ -0,0 +1,173 @@
+//go:build go1.18
+// +build go1.18
+
+package hjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testBackend struct {
+	Host string
+	Port int
+}
+
+type testOrderedMapOfConfig struct {
+	Backends OrderedMapOf[testBackend] `comment:"In order of preference"`
+	Names    OrderedMapOf[string]
+	Ports    *OrderedMapOf[int]
+}
+
+func TestOrderedMapOf(t *testing.T) {
+	var config testOrderedMapOfConfig
+	err := Unmarshal([]byte(`{
+  backends: {
+    zeta: {host: "z", port: 1}
+    alpha: {host: "a", port: 2}
+  }
+  names: {
+    b: 3
+    a: true
+    c: hello world
+  }
+  ports: {y: 1, x: 2}
+}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(config.Backends.Keys, []string{"zeta", "alpha"}) ||
+		config.Backends.Map["alpha"] != (testBackend{"a", 2}) {
+		t.Errorf("Unexpected backends: %+v", config.Backends)
+	}
+	if !reflect.DeepEqual(config.Names.Keys, []string{"b", "a", "c"}) ||
+		!reflect.DeepEqual(config.Names.Map, map[string]string{"a": "true", "b": "3", "c": "hello world"}) {
+		t.Errorf("Unexpected names: %+v", config.Names)
+	}
+	if v, ok := config.Ports.AtKey("x"); !ok || v != 2 || config.Ports.AtIndex(0) != 1 {
+		t.Errorf("Unexpected ports: %+v", config.Ports)
+	}
+
+	config.Backends.Insert(0, "beta", testBackend{"b", 3})
+	config.Names.DeleteKey("a")
+	out, err := Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  # In order of preference
+  Backends: {
+    beta: {
+      Host: b
+      Port: 3
+    }
+    zeta: {
+      Host: z
+      Port: 1
+    }
+    alpha: {
+      Host: a
+      Port: 2
+    }
+  }
+
+  Names: {
+    b: "3"
+    c: hello world
+  }
+  Ports: {
+    y: 1
+    x: 2
+  }
+}`
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, out)
+	}
+
+	out, err = json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config2 testOrderedMapOfConfig
+	if err = json.Unmarshal(out, &config2); err != nil {
+		t.Fatal(err)
+	}
+	// The structs differ in the internal index of their keys, so compare the
+	// output instead.
+	out2, err := json.Marshal(config2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out2) != string(out) {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", out, out2)
+	}
+
+	var om OrderedMapOf[int]
+	if err = Unmarshal([]byte(`[1, 2]`), &om); err == nil {
+		t.Error("Expected an error when unmarshalling an array into OrderedMapOf")
+	}
+}
+
+func TestOrderedMapOfOptions(t *testing.T) {
+	input := []byte(`{
+  backends: {
+    a: {host: "a", port: 1, weight: 2}
+  }
+}`)
+	options := DefaultDecoderOptions()
+	options.DisallowUnknownFields = true
+	var config testOrderedMapOfConfig
+	if err := UnmarshalWithOptions(input, &config, options); err == nil ||
+		!strings.Contains(err.Error(), `unknown field "weight"`) {
+		t.Errorf("Expected an unknown field error, got: %v", err)
+	}
+
+	options = DefaultDecoderOptions()
+	options.UseJSONNumber = true
+	var values OrderedMapOf[interface{}]
+	if err := UnmarshalWithOptions([]byte(`{b: 12345678901234567890, a: 1.5}`), &values, options); err != nil {
+		t.Fatal(err)
+	}
+	if values.AtIndex(0) != json.Number("12345678901234567890") || values.AtIndex(1) != json.Number("1.5") {
+		t.Errorf("Unexpected values: %+v", values.Map)
+	}
+}
+
+func TestOrderedMapOfDeleteKey(t *testing.T) {
+	om := NewOrderedMapOf[int]()
+	for i := 0; i < 1000; i++ {
+		om.Set(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 1000; i += 2 {
+		if value, ok := om.DeleteKey(strconv.Itoa(i)); !ok || value != i {
+			t.Fatalf("DeleteKey returned %d, %v for key %d", value, ok, i)
+		}
+	}
+	if _, ok := om.DeleteKey("0"); ok {
+		t.Error("DeleteKey returned true for a deleted key")
+	}
+	if om.Len() != 500 || om.AtIndex(0) != 1 || om.AtIndex(499) != 999 {
+		t.Errorf("Unexpected content after DeleteKey: %d keys", om.Len())
+	}
+}
+
+func TestOrderedMapOfValueCopy(t *testing.T) {
+	om := NewOrderedMapOf[int]()
+	om.Set("a", 1)
+	om.Set("b", 2)
+	om.DeleteKey("a")
+	om.Set("a", 1)
+
+	cp := *om
+	cp.Insert(0, "z", 3)
+	if !reflect.DeepEqual(cp.Keys, []string{"z", "b", "a"}) {
+		t.Errorf("Unexpected Keys in the copy: %v", cp.Keys)
+	}
+	if om.Len() != 2 || !reflect.DeepEqual(om.Keys, []string{"b", "a"}) {
+		t.Errorf("The original was changed by editing the copy: %v", om.Keys)
+	}
+}
@@ -0,0 +1,392 @@
+package hjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PatchOperation is a single operation in a JSON Patch (RFC 6902) document.
+//
+// Op must be one of "add", "remove", "replace", "move", "copy" or "test". Path
+// and From are JSON Pointers (RFC 6901). From is only used by "move" and
+// "copy", and Value is only used by "add", "replace" and "test". Value can be
+// of any type that can be marshalled by Marshal(). If Value is a *Node, its
+// comments are kept when it is added to the document.
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// Patch is a JSON Patch (RFC 6902) document, a list of operations to be
+// applied in order.
+type Patch []PatchOperation
+
+// MarshalJSON is an implementation of the json.Marshaler interface, enabling
+// hjson.PatchOperation to be used as input for json.Marshal(). Only the members
+// used by the operation are written.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	om := NewOrderedMap()
+	om.Set("op", op.Op)
+	om.Set("path", op.Path)
+	switch op.Op {
+	case "move", "copy":
+		om.Set("from", op.From)
+	case "add", "replace", "test":
+		om.Set("value", op.Value)
+	}
+	return json.Marshal(om)
+}
+
+// DecodePatch parses a JSON Patch document. Because all JSON is also valid
+// Hjson, the document can be written in either format. Returns an error if the
+// document is not an array of valid patch operations.
+func DecodePatch(data []byte) (Patch, error) {
+	var node Node
+	if err := Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	arr, ok := node.Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("A patch must be an array of operations")
+	}
+
+	patch := make(Patch, 0, len(arr))
+	for i, elem := range arr {
+		om, ok := elem.(*Node).Value.(*OrderedMap)
+		if !ok {
+			return nil, fmt.Errorf("Patch operation %d is not an object", i)
+		}
+
+		var op PatchOperation
+		var err error
+		if op.Op, err = patchMember(om, "op"); err != nil {
+			return nil, fmt.Errorf("Patch operation %d: %v", i, err)
+		}
+		if op.Path, err = patchMember(om, "path"); err != nil {
+			return nil, fmt.Errorf("Patch operation %d: %v", i, err)
+		}
+
+		switch op.Op {
+		case "remove":
+		case "move", "copy":
+			if op.From, err = patchMember(om, "from"); err != nil {
+				return nil, fmt.Errorf("Patch operation %d: %v", i, err)
+			}
+		case "add", "replace", "test":
+			value, ok := om.Map["value"]
+			if !ok {
+				return nil, fmt.Errorf("Patch operation %d: Missing member \"value\"", i)
+			}
+			op.Value = unwrapNodes(value)
+		default:
+			return nil, fmt.Errorf("Patch operation %d: Unknown op %q", i, op.Op)
+		}
+
+		patch = append(patch, op)
+	}
+
+	return patch, nil
+}
+
+// patchMember returns the string value for key in om.
+func patchMember(om *OrderedMap, key string) (string, error) {
+	elem, ok := om.Map[key]
+	if !ok {
+		return "", fmt.Errorf("Missing member %q", key)
+	}
+	s, ok := unwrapNodes(elem).(string)
+	if !ok {
+		return "", fmt.Errorf("Member %q must be a string", key)
+	}
+	return s, nil
+}
+
+// unwrapNodes returns a copy of value where all *Node elements have been
+// replaced by the values they wrap.
+func unwrapNodes(value interface{}) interface{} {
+	if node, ok := value.(*Node); ok {
+		value = node.Value
+	}
+	switch cont := value.(type) {
+	case *OrderedMap:
+		om := NewOrderedMap()
+		for _, key := range cont.Keys {
+			om.Set(key, unwrapNodes(cont.Map[key]))
+		}
+		return om
+	case []interface{}:
+		arr := make([]interface{}, len(cont))
+		for i, elem := range cont {
+			arr[i] = unwrapNodes(elem)
+		}
+		return arr
+	}
+	return value
+}
+
+// ApplyPatch applies the operations in patch to the Node tree c, in order.
+// The patch is applied atomically: if any operation fails, an error is
+// returned and c is left unchanged.
+//
+// Elements that are not touched by the patch keep their comments. An element
+// that is replaced, or that is added for a key that already exists, keeps the
+// comments of the old element unless the new value is a *Node with comments.
+// New keys are added to the end of their object, except when an element is
+// moved to a new key in the same object, in which case the key is renamed in
+// place. Moved elements keep their comments.
+func (c *Node) ApplyPatch(patch Patch) error {
+	if c == nil {
+		return fmt.Errorf("Node is nil")
+	}
+
+	// First apply the patch to a copy, so that c is only modified if every
+	// operation succeeds.
+	tmp := c.Clone()
+	for i, op := range patch {
+		if err := tmp.applyOperation(op); err != nil {
+			return fmt.Errorf("Failed to apply patch operation %d (%s %q): %v", i, op.Op, op.Path, err)
+		}
+	}
+
+	for _, op := range patch {
+		if err := c.applyOperation(op); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Node) applyOperation(op PatchOperation) error {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		node, err := NodeFrom(op.Value)
+		if err != nil {
+			return err
+		}
+		return c.patchAdd(path, node, -1)
+
+	case "remove":
+		_, _, err = c.patchRemove(path)
+		return err
+
+	case "replace":
+		node, err := NodeFrom(op.Value)
+		if err != nil {
+			return err
+		}
+		target, err := c.patchTarget(path)
+		if err != nil {
+			return err
+		}
+		replaceNode(target, node)
+		return nil
+
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		if isPointerPrefix(from, path) {
+			if len(from) == len(path) {
+				return nil
+			}
+			return fmt.Errorf("Cannot move %q into one of its children", op.From)
+		}
+		node, index, err := c.patchRemove(from)
+		if err != nil {
+			return err
+		}
+		if !isPointerPrefix(from[:len(from)-1], path) || len(path) != len(from) {
+			index = -1
+		}
+		return c.patchAdd(path, node, index)
+
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		node, err := c.patchTarget(from)
+		if err != nil {
+			return err
+		}
+		return c.patchAdd(path, node.Clone(), -1)
+
+	case "test":
+		node, err := c.patchTarget(path)
+		if err != nil {
+			return err
+		}
+		if !valuesEqual(node.Value, op.Value) {
+			return fmt.Errorf("Test failed")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Unknown op %q", op.Op)
+}
+
+// patchTarget returns the element identified by path, or an error if it does
+// not exist.
+func (c *Node) patchTarget(path []string) (*Node, error) {
+	node := c
+	for i, token := range path {
+		var elem interface{}
+		switch cont := node.Value.(type) {
+		case *OrderedMap:
+			elem = cont.Map[token]
+		case []interface{}:
+			if index, ok := pointerIndex(token); ok && index < len(cont) {
+				elem = cont[index]
+			}
+		}
+		if node = elemNode(elem); node == nil {
+			return nil, fmt.Errorf("Path not found: %s", formatPointer(path[:i+1]))
+		}
+	}
+	return node, nil
+}
+
+// patchAdd adds node at path. If the parent of path is an object and the key
+// does not already exist, the key is inserted at index, or appended if index
+// is negative.
+func (c *Node) patchAdd(path []string, node *Node, index int) error {
+	if len(path) == 0 {
+		replaceNode(c, node)
+		return nil
+	}
+
+	parent, err := c.patchTarget(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	token := path[len(path)-1]
+
+	switch cont := parent.Value.(type) {
+	case *OrderedMap:
+		if old := elemNode(cont.Map[token]); old != nil {
+			replaceNode(old, node)
+		} else if index >= 0 && index <= cont.Len() {
+			cont.Insert(index, token, node)
+		} else {
+			cont.Set(token, node)
+		}
+	case []interface{}:
+		i, ok := pointerIndex(token)
+		if token == "-" {
+			i, ok = len(cont), true
+		}
+		if !ok || i > len(cont) {
+			return fmt.Errorf("Invalid array index: %s", formatPointer(path))
+		}
+		cont = append(cont, nil)
+		copy(cont[i+1:], cont[i:])
+		cont[i] = node
+		parent.Value = cont
+	default:
+		return fmt.Errorf("Cannot add to a value of type %v", reflect.TypeOf(parent.Value))
+	}
+
+	return nil
+}
+
+// patchRemove removes the element identified by path, and returns it together
+// with its index in the parent object or array.
+func (c *Node) patchRemove(path []string) (*Node, int, error) {
+	if len(path) == 0 {
+		return nil, 0, fmt.Errorf("Cannot remove the root")
+	}
+	if _, err := c.patchTarget(path); err != nil {
+		return nil, 0, err
+	}
+
+	parent, _ := c.patchTarget(path[:len(path)-1])
+	token := path[len(path)-1]
+
+	switch cont := parent.Value.(type) {
+	case *OrderedMap:
+		if i := cont.IndexOf(token); i >= 0 {
+			_, elem := cont.DeleteIndex(i)
+			return elem.(*Node), i, nil
+		}
+	case []interface{}:
+		i, _ := pointerIndex(token)
+		node := cont[i].(*Node)
+		parent.Value = append(cont[:i], cont[i+1:]...)
+		return node, i, nil
+	}
+
+	return nil, 0, fmt.Errorf("Path not found: %s", formatPointer(path))
+}
+
+// replaceNode sets the value of dst to the value of src. The comments from
+// src are also used if src has any comments.
+func replaceNode(dst, src *Node) {
+	dst.Value = src.Value
+	if src.Cm != (Comments{}) {
+		dst.Cm = src.Cm
+	}
+}
+
+// isPointerPrefix returns true if the tokens in prefix are the first tokens in
+// path.
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, token := range prefix {
+		if path[i] != token {
+			return false
+		}
+	}
+	return true
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// formatPointer returns the JSON Pointer for a list of reference tokens.
+func formatPointer(tokens []string) string {
+	var res string
+	for _, token := range tokens {
+		res += "/" + pointerEscaper.Replace(token)
+	}
+	return res
+}
+
+// valuesEqual returns true if a and b contain the same values, ignoring any
+// comments and key order. Numbers are compared numerically.
+func valuesEqual(a, b interface{}) bool {
+	return Equal(plainValue(a), plainValue(b), EqualOptions{
+		IgnoreComments:     true,
+		IgnoreKeyOrder:     true,
+		NumbersNumerically: true,
+	})
+}
+
+// plainValue returns value converted to the types found in a Node tree, but
+// without any *Node elements. Returns nil for values that cannot be
+// converted.
+func plainValue(value interface{}) interface{} {
+	switch value.(type) {
+	case *Node, *OrderedMap, []interface{}:
+		return unwrapNodes(value)
+	case nil, bool, string, float64, json.Number:
+		return value
+	}
+	node, err := NodeFrom(value)
+	if err != nil {
+		return nil
+	}
+	return unwrapNodes(node)
+}
@@ -0,0 +1,194 @@
+package hjson
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var patchText = `# config
+{
+  # the name
+  name: app
+  servers: [
+    alpha
+    beta
+  ]
+  # port to listen on
+  port: 80 # default
+  old: value
+}`
+
+func marshalPatched(t *testing.T, node *Node) string {
+	b, err := Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestApplyPatch(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[
+  {"op": "test", "path": "/port", "value": 80}
+  {"op": "replace", "path": "/port", "value": 8080}
+  {"op": "add", "path": "/servers/1", "value": "gamma"}
+  {"op": "add", "path": "/servers/-", "value": "delta"}
+  {"op": "remove", "path": "/servers/0"}
+  {"op": "move", "from": "/old", "path": "/new"}
+  {"op": "copy", "from": "/servers", "path": "/backup"}
+  {"op": "add", "path": "/limits", "value": {"max": 10, "min": 1}}
+  {"op": "test", "path": "/limits", "value": {"min": 1, "max": 10.0}}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := unmarshalNode(t, patchText)
+	if err = node.ApplyPatch(patch); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# config
+{
+  # the name
+  name: app
+  servers: [
+    gamma
+    beta
+    delta
+  ]
+  # port to listen on
+  port: 8080 # default
+  new: value
+  backup: [
+    gamma
+    beta
+    delta
+  ]
+  limits: {
+    max: 10
+    min: 1
+  }
+}`
+	if res := marshalPatched(t, node); res != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, res)
+	}
+
+	// The copy must not share elements with the original.
+	node.NK("backup").NI(0).Value = "x"
+	if node.NK("servers").NI(0).Value != "gamma" {
+		t.Error("Copied element was shared")
+	}
+}
+
+func TestApplyPatchComments(t *testing.T) {
+	node := unmarshalNode(t, patchText)
+	err := node.ApplyPatch(Patch{
+		{Op: "add", Path: "/name", Value: "other"},
+		{Op: "move", From: "/port", Path: "/listen"},
+		{Op: "add", Path: "/debug", Value: &Node{Value: true, Cm: Comments{After: " # temporary"}}},
+		{Op: "add", Path: "/server", Value: map[string]int{"port": 1}},
+		{Op: "move", From: "/servers/1", Path: "/servers/0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# config
+{
+  # the name
+  name: other
+  servers: [
+    beta
+    alpha
+  ]
+  # port to listen on
+  listen: 80 # default
+  old: value
+  debug: true # temporary
+  server: {
+    port: 1
+  }
+}`
+	if res := marshalPatched(t, node); res != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, res)
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	testCases := []struct {
+		op  PatchOperation
+		err string
+	}{
+		{PatchOperation{Op: "test", Path: "/port", Value: 81}, "Test failed"},
+		{PatchOperation{Op: "test", Path: "/servers", Value: []string{"alpha"}}, "Test failed"},
+		{PatchOperation{Op: "remove", Path: "/missing"}, "Path not found: /missing"},
+		{PatchOperation{Op: "remove", Path: ""}, "Cannot remove the root"},
+		{PatchOperation{Op: "replace", Path: "/servers/2", Value: 1}, "Path not found: /servers/2"},
+		{PatchOperation{Op: "add", Path: "/servers/3", Value: 1}, "Invalid array index: /servers/3"},
+		{PatchOperation{Op: "add", Path: "/a/b", Value: 1}, "Path not found: /a"},
+		{PatchOperation{Op: "add", Path: "/name/x", Value: 1}, "Cannot add to a value of type string"},
+		{PatchOperation{Op: "move", From: "/servers", Path: "/servers/0"}, "into one of its children"},
+		{PatchOperation{Op: "copy", From: "/x~1y", Path: "/z"}, "Path not found: /x~1y"},
+		{PatchOperation{Op: "add", Path: "name", Value: 1}, "Invalid JSON Pointer"},
+		{PatchOperation{Op: "merge", Path: "/name"}, "Unknown op"},
+	}
+
+	for _, tc := range testCases {
+		node := unmarshalNode(t, patchText)
+		patch := Patch{
+			{Op: "replace", Path: "/name", Value: "changed"},
+			tc.op,
+		}
+		err := node.ApplyPatch(patch)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: Expected error containing %q, got %v", tc.op, tc.err, err)
+		}
+		// Nothing is changed if any operation fails.
+		if res := marshalPatched(t, node); res != patchText {
+			t.Errorf("%v: Expected unchanged node, got:\n%s", tc.op, res)
+		}
+	}
+
+	if err := unmarshalNode(t, patchText).ApplyPatch(Patch{{Op: "test", Path: "/servers", Value: []string{"alpha", "beta"}}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodePatch(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[
+    # Hjson is also accepted.
+    {
+      op: add
+      path: /a
+      value: null
+    }
+    {op: "move", from: "/a", path: "/b"}
+    {op: "remove", path: "/b"}
+  ]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"op":"add","path":"/a","value":null},{"op":"move","path":"/b","from":"/a"},{"op":"remove","path":"/b"}]`
+	if string(b) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, string(b))
+	}
+
+	for _, text := range []string{
+		`{}`,
+		`[1]`,
+		`[{path: "/a"}]`,
+		`[{op: "add", path: "/a"}]`,
+		`[{op: "move", path: "/a"}]`,
+		`[{op: "add", path: 1, value: 1}]`,
+		`[{op: "foo", path: "/a"}]`,
+	} {
+		if _, err := DecodePatch([]byte(text)); err == nil {
+			t.Errorf("%s: Expected an error", text)
+		}
+	}
+}
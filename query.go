@@ -0,0 +1,715 @@
+package hjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Get returns the element identified by the JSON Pointer (RFC 6901) path, for
+// example "/servers/0/host". The empty path "" identifies the Node c itself.
+// Only elements of type *Node can be found, as in any tree created by
+// Unmarshal() with a *Node destination. Returns nil if no element is found for
+// the path, and an error if the path is not a valid JSON Pointer.
+func (c *Node) Get(path string) (*Node, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node := c
+	for _, token := range tokens {
+		if node == nil {
+			return nil, nil
+		}
+		switch cont := node.Value.(type) {
+		case *OrderedMap:
+			node = elemNode(cont.Map[token])
+		case []interface{}:
+			index, ok := pointerIndex(token)
+			if !ok || index >= len(cont) {
+				return nil, nil
+			}
+			node = elemNode(cont[index])
+		default:
+			return nil, nil
+		}
+	}
+
+	return node, nil
+}
+
+// parsePointer splits a JSON Pointer into unescaped reference tokens.
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("Invalid JSON Pointer %q: must be empty or start with '/'", path)
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		if !strings.Contains(token, "~") {
+			continue
+		}
+		var b strings.Builder
+		for j := 0; j < len(token); j++ {
+			if token[j] != '~' {
+				b.WriteByte(token[j])
+				continue
+			}
+			j++
+			switch {
+			case j < len(token) && token[j] == '0':
+				b.WriteByte('~')
+			case j < len(token) && token[j] == '1':
+				b.WriteByte('/')
+			default:
+				return nil, fmt.Errorf("Invalid JSON Pointer %q: '~' must be followed by '0' or '1'", path)
+			}
+		}
+		tokens[i] = b.String()
+	}
+
+	return tokens, nil
+}
+
+// pointerIndex returns the array index for a JSON Pointer reference token.
+// Returns false if the token is not a valid array index.
+func pointerIndex(token string) (int, bool) {
+	if token == "" || len(token) > 1 && token[0] == '0' {
+		return 0, false
+	}
+	for _, ch := range token {
+		if ch < '0' || ch > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(token)
+	return index, err == nil
+}
+
+// elemNode returns elem if it is a *Node, otherwise nil.
+func elemNode(elem interface{}) *Node {
+	node, _ := elem.(*Node)
+	return node
+}
+
+// childNodes returns all elements of type *Node in c, in order.
+func (c *Node) childNodes() []*Node {
+	var nodes []*Node
+	switch cont := c.Value.(type) {
+	case *OrderedMap:
+		for _, key := range cont.Keys {
+			if node := elemNode(cont.Map[key]); node != nil {
+				nodes = append(nodes, node)
+			}
+		}
+	case []interface{}:
+		for _, elem := range cont {
+			if node := elemNode(elem); node != nil {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+// Query returns all elements matching the JSONPath expression expr, in
+// document order. These parts of JSONPath are supported:
+//
+//	$                   the root, which is the Node c
+//	.key or ['key']     the element with the specified key in an object
+//	[n]                 the element at index n in an array, negative n counts
+//	                    from the end of the array
+//	[start:end]         the elements from start up to (but not including) end
+//	                    in an array, both start and end are optional
+//	.* or [*]           all elements in an object or an array
+//	[a,b]               the union of the selectors a and b
+//	..                  recursive descent, for example $..key
+//	[?(filter)]         the elements for which the filter expression is true
+//
+// A filter expression can compare values found relative to the element (@)
+// with each other or with literals (numbers, strings, true, false, null),
+// using the operators ==, !=, <, <=, > and >=. A path without comparison tests
+// for existence. Expressions can be combined using &&, || and !, and grouped
+// using parentheses. Example: $.servers[?(@.port >= 8000 && @.host != 'x')]
+//
+// Only elements of type *Node can be found, as in any tree created by
+// Unmarshal() with a *Node destination. Returns an error if expr is not a
+// valid expression.
+func (c *Node) Query(expr string) ([]*Node, error) {
+	segments, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*Node{c}
+	for _, seg := range segments {
+		var next []*Node
+		for _, node := range nodes {
+			if seg.recursive {
+				node.Walk(func(_ []PathElem, n *Node) error {
+					next = seg.selectFrom(n, next)
+					return nil
+				})
+			} else {
+				next = seg.selectFrom(node, next)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes, nil
+}
+
+type querySelectorKind int
+
+const (
+	selectKey querySelectorKind = iota
+	selectIndex
+	selectSlice
+	selectWildcard
+	selectFilter
+)
+
+type querySelector struct {
+	kind   querySelectorKind
+	key    string
+	index  int
+	start  *int
+	end    *int
+	filter func(*Node) bool
+}
+
+type querySegment struct {
+	recursive bool
+	selectors []querySelector
+}
+
+// selectFrom appends the children of node that match any of the selectors in
+// seg to res.
+func (seg querySegment) selectFrom(node *Node, res []*Node) []*Node {
+	for _, sel := range seg.selectors {
+		switch sel.kind {
+		case selectKey:
+			if om, ok := node.Value.(*OrderedMap); ok {
+				if elem := elemNode(om.Map[sel.key]); elem != nil {
+					res = append(res, elem)
+				}
+			}
+		case selectIndex:
+			if arr, ok := node.Value.([]interface{}); ok {
+				index := sel.index
+				if index < 0 {
+					index += len(arr)
+				}
+				if index >= 0 && index < len(arr) {
+					if elem := elemNode(arr[index]); elem != nil {
+						res = append(res, elem)
+					}
+				}
+			}
+		case selectSlice:
+			if arr, ok := node.Value.([]interface{}); ok {
+				start, end := sliceBounds(sel.start, sel.end, len(arr))
+				for i := start; i < end; i++ {
+					if elem := elemNode(arr[i]); elem != nil {
+						res = append(res, elem)
+					}
+				}
+			}
+		case selectWildcard:
+			res = append(res, node.childNodes()...)
+		case selectFilter:
+			for _, child := range node.childNodes() {
+				if sel.filter(child) {
+					res = append(res, child)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// sliceBounds returns the normalized bounds for an array slice.
+func sliceBounds(pStart, pEnd *int, length int) (int, int) {
+	normalize := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > length {
+			return length
+		}
+		return i
+	}
+	start := normalize(pStart, 0)
+	end := normalize(pEnd, length)
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+type queryParser struct {
+	expr string
+	pos  int
+}
+
+func (q *queryParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("Invalid JSONPath %q at position %d: %s", q.expr, q.pos,
+		fmt.Sprintf(format, a...))
+}
+
+func (q *queryParser) eof() bool {
+	return q.pos >= len(q.expr)
+}
+
+func (q *queryParser) peek() byte {
+	if q.eof() {
+		return 0
+	}
+	return q.expr[q.pos]
+}
+
+func (q *queryParser) skipSpace() {
+	for !q.eof() && (q.expr[q.pos] == ' ' || q.expr[q.pos] == '\t') {
+		q.pos++
+	}
+}
+
+// consume skips s and returns true if the expression continues with s.
+func (q *queryParser) consume(s string) bool {
+	if strings.HasPrefix(q.expr[q.pos:], s) {
+		q.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func isQueryNameChar(ch byte) bool {
+	return ch == '_' || ch == '-' || ch == '$' || ch >= 0x80 ||
+		ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+// readName reads a key used in dot notation.
+func (q *queryParser) readName() (string, error) {
+	start := q.pos
+	for !q.eof() && isQueryNameChar(q.expr[q.pos]) {
+		q.pos++
+	}
+	if q.pos == start {
+		return "", q.errorf("expected a key name")
+	}
+	return q.expr[start:q.pos], nil
+}
+
+// readString reads a string in single or double quotes.
+func (q *queryParser) readString() (string, error) {
+	quote := q.peek()
+	q.pos++
+	var b strings.Builder
+	for !q.eof() {
+		ch := q.expr[q.pos]
+		q.pos++
+		switch ch {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if q.eof() {
+				return "", q.errorf("unterminated string")
+			}
+			ch = q.expr[q.pos]
+			q.pos++
+			ech, ok := escapee[ch]
+			if !ok {
+				return "", q.errorf("bad escape \\%c", ch)
+			}
+			b.WriteByte(ech)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return "", q.errorf("unterminated string")
+}
+
+// readInt reads an optionally negative integer. Returns nil if there is no
+// integer at the current position.
+func (q *queryParser) readInt() (*int, error) {
+	start := q.pos
+	q.consume("-")
+	for !q.eof() && q.expr[q.pos] >= '0' && q.expr[q.pos] <= '9' {
+		q.pos++
+	}
+	if q.pos == start {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(q.expr[start:q.pos])
+	if err != nil {
+		return nil, q.errorf("invalid integer %q", q.expr[start:q.pos])
+	}
+	return &i, nil
+}
+
+func parseQuery(expr string) ([]querySegment, error) {
+	q := &queryParser{expr: expr}
+	q.skipSpace()
+	if !q.consume("$") {
+		return nil, q.errorf("must start with '$'")
+	}
+
+	var segments []querySegment
+	for q.skipSpace(); !q.eof(); q.skipSpace() {
+		var seg querySegment
+		var err error
+		switch {
+		case q.consume(".."):
+			seg.recursive = true
+			if q.peek() == '[' {
+				seg.selectors, err = q.parseBracket()
+			} else {
+				seg.selectors, err = q.parseDotSelector()
+			}
+		case q.consume("."):
+			seg.selectors, err = q.parseDotSelector()
+		case q.peek() == '[':
+			seg.selectors, err = q.parseBracket()
+		default:
+			err = q.errorf("unexpected character '%c'", q.peek())
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+func (q *queryParser) parseDotSelector() ([]querySelector, error) {
+	if q.consume("*") {
+		return []querySelector{{kind: selectWildcard}}, nil
+	}
+	name, err := q.readName()
+	if err != nil {
+		return nil, err
+	}
+	return []querySelector{{kind: selectKey, key: name}}, nil
+}
+
+// parseBracket parses a bracketed list of selectors, starting at '['.
+func (q *queryParser) parseBracket() ([]querySelector, error) {
+	q.pos++ // Skip '['.
+	var selectors []querySelector
+	for {
+		q.skipSpace()
+		var sel querySelector
+		switch ch := q.peek(); {
+		case ch == '*':
+			q.pos++
+			sel.kind = selectWildcard
+		case ch == '\'' || ch == '"':
+			key, err := q.readString()
+			if err != nil {
+				return nil, err
+			}
+			sel.kind, sel.key = selectKey, key
+		case ch == '?':
+			q.pos++
+			filter, err := q.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			sel.kind, sel.filter = selectFilter, filter
+		default:
+			start, err := q.readInt()
+			if err != nil {
+				return nil, err
+			}
+			q.skipSpace()
+			if q.consume(":") {
+				q.skipSpace()
+				end, err := q.readInt()
+				if err != nil {
+					return nil, err
+				}
+				sel.kind, sel.start, sel.end = selectSlice, start, end
+			} else if start != nil {
+				sel.kind, sel.index = selectIndex, *start
+			} else {
+				return nil, q.errorf("expected a selector")
+			}
+		}
+		selectors = append(selectors, sel)
+
+		q.skipSpace()
+		if q.consume("]") {
+			return selectors, nil
+		}
+		if !q.consume(",") {
+			return nil, q.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+// queryOperand returns a value for the element, and false if the value does
+// not exist.
+type queryOperand func(*Node) (interface{}, bool)
+
+func (q *queryParser) parseOr() (func(*Node) bool, error) {
+	left, err := q.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for q.skipSpace(); q.consume("||"); q.skipSpace() {
+		right, err := q.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(n *Node) bool { return l(n) || right(n) }
+	}
+	return left, nil
+}
+
+func (q *queryParser) parseAnd() (func(*Node) bool, error) {
+	left, err := q.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for q.skipSpace(); q.consume("&&"); q.skipSpace() {
+		right, err := q.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(n *Node) bool { return l(n) && right(n) }
+	}
+	return left, nil
+}
+
+func (q *queryParser) parseUnary() (func(*Node) bool, error) {
+	q.skipSpace()
+	if q.peek() == '!' && !strings.HasPrefix(q.expr[q.pos:], "!=") {
+		q.pos++
+		inner, err := q.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(n *Node) bool { return !inner(n) }, nil
+	}
+	if q.consume("(") {
+		inner, err := q.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		q.skipSpace()
+		if !q.consume(")") {
+			return nil, q.errorf("expected ')'")
+		}
+		return inner, nil
+	}
+	return q.parseComparison()
+}
+
+var queryOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (q *queryParser) parseComparison() (func(*Node) bool, error) {
+	left, err := q.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	q.skipSpace()
+	for _, op := range queryOperators {
+		if q.consume(op) {
+			right, err := q.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return func(n *Node) bool {
+				a, okA := left(n)
+				b, okB := right(n)
+				if !okA || !okB {
+					// A missing value is only equal to another missing value.
+					return (op == "==") == (okA == okB)
+				}
+				return compareQueryValues(a, op, b)
+			}, nil
+		}
+	}
+	// Test for existence.
+	return func(n *Node) bool {
+		_, ok := left(n)
+		return ok
+	}, nil
+}
+
+func (q *queryParser) parseOperand() (queryOperand, error) {
+	q.skipSpace()
+	ch := q.peek()
+	switch {
+	case ch == '@':
+		q.pos++
+		return q.parseRelativePath()
+	case ch == '\'' || ch == '"':
+		s, err := q.readString()
+		if err != nil {
+			return nil, err
+		}
+		return literalOperand(s), nil
+	case q.consume("true"):
+		return literalOperand(true), nil
+	case q.consume("false"):
+		return literalOperand(false), nil
+	case q.consume("null"):
+		return literalOperand(nil), nil
+	case ch == '-' || ch >= '0' && ch <= '9':
+		start := q.pos
+		for q.pos++; !q.eof() && strings.IndexByte("0123456789.eE+-", q.expr[q.pos]) >= 0; q.pos++ {
+		}
+		f, err := strconv.ParseFloat(q.expr[start:q.pos], 64)
+		if err != nil {
+			return nil, q.errorf("invalid number %q", q.expr[start:q.pos])
+		}
+		return literalOperand(f), nil
+	}
+	return nil, q.errorf("expected a value or a path starting with '@'")
+}
+
+func literalOperand(value interface{}) queryOperand {
+	return func(*Node) (interface{}, bool) { return value, true }
+}
+
+// parseRelativePath parses a path following '@' in a filter expression.
+func (q *queryParser) parseRelativePath() (queryOperand, error) {
+	var path []querySelector
+	for {
+		var sel querySelector
+		if q.peek() == '.' && !strings.HasPrefix(q.expr[q.pos:], "..") {
+			q.pos++
+			name, err := q.readName()
+			if err != nil {
+				return nil, err
+			}
+			sel.kind, sel.key = selectKey, name
+		} else if q.peek() == '[' {
+			q.pos++
+			q.skipSpace()
+			if ch := q.peek(); ch == '\'' || ch == '"' {
+				key, err := q.readString()
+				if err != nil {
+					return nil, err
+				}
+				sel.kind, sel.key = selectKey, key
+			} else {
+				index, err := q.readInt()
+				if err != nil {
+					return nil, err
+				}
+				if index == nil {
+					return nil, q.errorf("expected a key or an index")
+				}
+				sel.kind, sel.index = selectIndex, *index
+			}
+			q.skipSpace()
+			if !q.consume("]") {
+				return nil, q.errorf("expected ']'")
+			}
+		} else {
+			break
+		}
+		path = append(path, sel)
+	}
+
+	return func(n *Node) (interface{}, bool) {
+		for _, sel := range path {
+			var res []*Node
+			res = querySegment{selectors: []querySelector{sel}}.selectFrom(n, res)
+			if len(res) == 0 {
+				return nil, false
+			}
+			n = res[0]
+		}
+		return n.Value, true
+	}, nil
+}
+
+// floatValue returns value as a float64, if value is a number of any Go
+// numeric type or a json.Number.
+func floatValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// compareQueryValues compares a with b using the operator op. Values of
+// different types are never equal, and can not be ordered.
+func compareQueryValues(a interface{}, op string, b interface{}) bool {
+	var cmp int
+	if fa, ok := floatValue(a); ok {
+		fb, ok := floatValue(b)
+		if !ok {
+			return op == "!="
+		}
+		switch {
+		case fa < fb:
+			cmp = -1
+		case fa > fb:
+			cmp = 1
+		}
+	} else if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return op == "!="
+		}
+		cmp = strings.Compare(sa, sb)
+	} else {
+		// Only equality can be tested for other types.
+		equal := reflect.DeepEqual(a, b)
+		switch op {
+		case "==":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
@@ -0,0 +1,136 @@
+package hjson
+
+import (
+	"strings"
+	"testing"
+)
+
+var queryText = `{
+  name: store
+  "a/b": 1
+  "m~n": 2
+  servers: [
+    {host: "alpha", port: 80, enabled: true}
+    {host: "beta", port: 8080, tags: ["x"]}
+    {host: "gamma", port: 9090, enabled: false}
+  ]
+  nested: {
+    host: delta
+    list: [1, 2, 3, 4]
+  }
+}`
+
+func TestNodeGet(t *testing.T) {
+	node := unmarshalNode(t, queryText)
+
+	testCases := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"/name", "store"},
+		{"/a~1b", 1.0},
+		{"/m~0n", 2.0},
+		{"/servers/1/host", "beta"},
+		{"/servers/1/tags/0", "x"},
+		{"/nested/list/3", 4.0},
+	}
+	for _, tc := range testCases {
+		res, err := node.Get(tc.path)
+		if err != nil {
+			t.Errorf("%s: %v", tc.path, err)
+		} else if res == nil || res.Value != tc.expected {
+			t.Errorf("%s: Expected %#v, got %#v", tc.path, tc.expected, res)
+		}
+	}
+
+	if res, err := node.Get(""); err != nil || res != node {
+		t.Errorf("Expected the root node, got %#v %v", res, err)
+	}
+
+	for _, path := range []string{"/missing", "/servers/3", "/servers/01", "/servers/-", "/name/x"} {
+		if res, err := node.Get(path); err != nil || res != nil {
+			t.Errorf("%s: Expected nil, got %#v %v", path, res, err)
+		}
+	}
+
+	for _, path := range []string{"name", "/a~2b"} {
+		if _, err := node.Get(path); err == nil {
+			t.Errorf("%s: Expected an error", path)
+		}
+	}
+}
+
+func TestNodeQuery(t *testing.T) {
+	node := unmarshalNode(t, queryText)
+
+	testCases := []struct {
+		expr     string
+		expected string
+	}{
+		{"$.name", "store"},
+		{"$['a/b']", "1"},
+		{"$.servers[0].host", "alpha"},
+		{"$.servers[-1].host", "gamma"},
+		{"$.servers[*].host", "alpha beta gamma"},
+		{"$.servers.*.port", "80 8080 9090"},
+		{"$.servers[0:2].host", "alpha beta"},
+		{"$.servers[1:].host", "beta gamma"},
+		{"$.nested.list[:-2]", "1 2"},
+		{"$.nested.list[0,2]", "1 3"},
+		{"$['name','a/b']", "store 1"},
+		{"$..host", "alpha beta gamma delta"},
+		{"$..list[1]", "2"},
+		{"$.servers[?(@.port > 100)].host", "beta gamma"},
+		{"$.servers[?(@.port >= 80 && @.host != 'beta')].host", "alpha gamma"},
+		{"$.servers[?(@.enabled)].host", "alpha gamma"},
+		{"$.servers[?(!@.enabled)].host", "beta"},
+		{"$.servers[?(@.enabled == false || @.tags[0] == \"x\")].host", "beta gamma"},
+		{"$.servers[?(@.host < 'c')].port", "80 8080"},
+		{"$.nested.list[?(@ % 2)]", ""},
+		{"$.nested.list[?(@ == 2 || (@ > 3))]", "2 4"},
+		{"$..[?(@.host == 'delta')].list[0]", "1"},
+		{"$.missing", ""},
+	}
+	for _, tc := range testCases {
+		res, err := node.Query(tc.expr)
+		if strings.Contains(tc.expr, "%") {
+			if err == nil {
+				t.Errorf("%s: Expected an error", tc.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		var values []string
+		for _, n := range res {
+			b, err := Marshal(n.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, string(b))
+		}
+		if strings.Join(values, " ") != tc.expected {
+			t.Errorf("%s: Expected %q, got %q", tc.expr, tc.expected, strings.Join(values, " "))
+		}
+	}
+
+	for _, expr := range []string{"", "name", "$.", "$[", "$[?(@.a ==)]", "$['x]", "$.a b"} {
+		if _, err := node.Query(expr); err == nil {
+			t.Errorf("%q: Expected an error", expr)
+		}
+	}
+
+	// The returned nodes can be modified.
+	res, err := node.Query("$.servers[*].port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range res {
+		n.Cm.After = " # port"
+	}
+	if node.NK("servers").NI(2).NK("port").Cm.After != " # port" {
+		t.Error("Expected the comment to be set in the tree")
+	}
+}
@@ -0,0 +1,120 @@
+package hjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// RawMessage is a raw encoded Hjson value. It can be used to delay the
+// decoding of a part of the Hjson input, or to write pre-encoded Hjson.
+//
+// When RawMessage is used as destination for Unmarshal() or
+// UnmarshalWithOptions(), it will contain the exact bytes of the value from
+// the Hjson input, including any comments inside the value (but not the
+// comments before or after the value). When a RawMessage is marshalled by
+// Marshal() or MarshalWithOptions(), it is written as is, except that the
+// indentation of any lines after the first is adjusted to fit the
+// surrounding output.
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+// MarshalJSON is an implementation of the json.Marshaler interface, enabling
+// hjson.RawMessage to be used as input for json.Marshal(). The Hjson value in
+// m is converted to JSON.
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	decOpt := DefaultDecoderOptions()
+	decOpt.UseJSONNumber = true
+	var dummyDest interface{}
+	value, err := orderedUnmarshal(m, &dummyDest, decOpt, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON is an implementation of the json.Unmarshaler interface,
+// enabling hjson.RawMessage to be used as destination for json.Unmarshal().
+// Sets *m to a copy of data.
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+// isRawMessage returns true if the destination is of type hjson.RawMessage,
+// or a pointer to hjson.RawMessage. dest and t must not have been unraveled.
+func isRawMessage(dest reflect.Value, t reflect.Type) bool {
+	_, t = unravelDestination(dest, t)
+	return t == rawMessageType
+}
+
+// rawMessage returns a copy of the input from the offset start to the current
+// position, excluding any trailing whitespace.
+func (p *hjsonParser) rawMessage(start int) RawMessage {
+	return append(RawMessage{}, p.data[start:p.valueEnd(start)]...)
+}
+
+// assignRaw stores raw in v, which must be of type hjson.RawMessage (or a
+// pointer to it) or an empty interface.
+func (p *hjsonParser) assignRaw(raw RawMessage, v reflect.Value) {
+	isNull := string(raw) == "null"
+	for a := 0; a < maxPointerDepth && v.Kind() == reflect.Ptr; a++ {
+		if isNull {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == rawMessageType:
+		v.SetBytes(raw)
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		v.Set(reflect.ValueOf(raw))
+	default:
+		p.typeError("raw message", v.Type())
+	}
+}
+
+// writeRaw writes raw to the output, with the indentation of any lines after
+// the first line adjusted to e.indent.
+func (e *hjsonEncoder) writeRaw(raw []byte, separator string) {
+	e.WriteString(separator)
+	if raw == nil {
+		e.WriteString("null")
+		return
+	}
+
+	lines := bytes.Split(bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1), []byte("\n"))
+
+	// Find the indentation common to all non-empty lines after the first line.
+	minIndent := -1
+	for _, line := range lines[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 {
+			continue
+		}
+		if indent := len(line) - len(trimmed); minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+
+	e.Write(lines[0])
+	for _, line := range lines[1:] {
+		e.WriteString(e.Eol)
+		if len(bytes.TrimLeft(line, " \t")) == 0 {
+			continue
+		}
+		e.writeIndentNoEOL(e.indent)
+		e.Write(line[minIndent:])
+	}
+}
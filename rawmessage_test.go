@@ -0,0 +1,113 @@
+package hjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRawMessageUnmarshal(t *testing.T) {
+	txt := []byte(`# envelope
+type: server
+payload: {
+  # the host
+  host: example.com
+  ports: [80, 443] // both
+}
+list: [
+  1
+  quoteless text
+]
+extra: null
+`)
+	type envelope struct {
+		Type    string
+		Payload RawMessage
+		List    []RawMessage
+		Extra   *RawMessage
+	}
+
+	var env envelope
+	if err := Unmarshal(txt, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Type != "server" {
+		t.Errorf("Unexpected type: %q", env.Type)
+	}
+	compareStrings(t, env.Payload, `{
+  # the host
+  host: example.com
+  ports: [80, 443] // both
+}`)
+	if !reflect.DeepEqual(env.List, []RawMessage{RawMessage("1"), RawMessage("quoteless text")}) {
+		t.Errorf("Unexpected list: %q", env.List)
+	}
+	if env.Extra != nil {
+		t.Errorf("Expected nil, got %q", *env.Extra)
+	}
+
+	var payload struct {
+		Host  string
+		Ports []int
+	}
+	if err := Unmarshal(env.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Host != "example.com" || !reflect.DeepEqual(payload.Ports, []int{80, 443}) {
+		t.Errorf("Unexpected payload: %#v", payload)
+	}
+
+	var m map[string]RawMessage
+	if err := Unmarshal(txt, &m); err != nil {
+		t.Fatal(err)
+	}
+	if string(m["extra"]) != "null" || string(m["type"]) != "server" {
+		t.Errorf("Unexpected map: %q", m)
+	}
+
+	var root RawMessage
+	if err := Unmarshal([]byte("\n  [1, 2]  \n"), &root); err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, root, `[1, 2]`)
+}
+
+func TestRawMessageMarshal(t *testing.T) {
+	v := map[string]interface{}{
+		"a": RawMessage(`{
+      # comment
+      b: [
+        1
+      ]
+    }`),
+		"c": []interface{}{RawMessage(`x  # y`), RawMessage(nil)},
+	}
+	h, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, h, `{
+  a: {
+    # comment
+    b: [
+      1
+    ]
+  }
+  c: [
+    x  # y
+    null
+  ]
+}`)
+
+	j, err := json.Marshal(struct{ A RawMessage }{RawMessage(`{b: 1, a: ["x"]}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, j, `{"A":{"b":1,"a":["x"]}}`)
+
+	var s struct{ A RawMessage }
+	if err = json.Unmarshal(j, &s); err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, s.A, `{"b":1,"a":["x"]}`)
+}
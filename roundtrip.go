@@ -0,0 +1,149 @@
+package hjson
+
+import (
+	"reflect"
+	"strings"
+)
+
+// UnmarshalWithNode parses the Hjson-encoded data and stores the result in the
+// value pointed to by v, just like UnmarshalWithOptions(). It also returns
+// the Node tree for the data, containing all comments and whitespace from the
+// input. The Node tree can later be passed to MarshalWithNode(), together with
+// the same (possibly modified) value v, to write v as Hjson while keeping the
+// comments from the original input.
+//
+// The data is only parsed once, into the Node tree. The values are then stored
+// in v from the Node tree, using the positions of the values in data to read
+// them as UnmarshalWithOptions() would have read them for v.
+func UnmarshalWithNode(data []byte, v interface{}, options DecoderOptions) (*Node, error) {
+	p := newHjsonParser(data, options)
+	p.nodeSpans = map[*Node][2]int{}
+	var node Node
+	if err := p.unmarshalData(&node); err != nil {
+		return nil, err
+	}
+
+	dp := newHjsonParser(data, options)
+	dp.nodeSpans = p.nodeSpans
+	err := dp.unmarshal(v, func(rv reflect.Value) (interface{}, error) {
+		if !dp.willAssign && !dp.nodeDestination {
+			// The destination is an *OrderedMap.
+			return unwrapNodes(&node), nil
+		}
+		return &node, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+// MarshalWithNode returns the Hjson encoding of v, using the comments,
+// whitespace and key order from node for every value in v that also exists in
+// node. The node should have been returned from UnmarshalWithNode(), or from
+// unmarshalling into an hjson.Node.
+//
+// Object keys are matched case-insensitively if no exact match is found, and
+// then the key from node is used in the output. Keys in v that do not exist in
+// node are placed after the existing keys in the same object. Array elements
+// are matched by index.
+//
+// See MarshalWithOptions() for details about the conversion of v to Hjson.
+func MarshalWithNode(v interface{}, node *Node, options EncoderOptions) ([]byte, error) {
+	if node == nil {
+		return MarshalWithOptions(v, options)
+	}
+
+	// Convert v to a Node tree, with comments from any struct tags, that the
+	// comments from node can be copied to.
+	newNode, err := NodeFrom(v)
+	if err != nil {
+		return nil, err
+	}
+
+	copyComments(newNode, node)
+
+	return MarshalWithOptions(newNode, options)
+}
+
+// copyComments copies the comments from old to node, and recursively to all
+// elements in node that also exist in old. The key order from old is applied
+// to objects in node.
+func copyComments(node, old *Node) {
+	node.Cm.copyFrom(old.Cm, CommentBefore, CommentKey, CommentAfter)
+
+	switch nv := node.Value.(type) {
+	case *OrderedMap:
+		ov, ok := old.Value.(*OrderedMap)
+		if !ok {
+			return
+		}
+		node.Cm.copyFrom(old.Cm, CommentInsideFirst, CommentInsideLast)
+		node.Value = copyObjectComments(nv, ov)
+
+	case []interface{}:
+		ov, ok := old.Value.([]interface{})
+		if !ok {
+			return
+		}
+		node.Cm.copyFrom(old.Cm, CommentInsideFirst, CommentInsideLast)
+		for i := 0; i < len(nv) && i < len(ov); i++ {
+			copyElemComments(nv[i], ov[i])
+		}
+	}
+}
+
+func copyElemComments(elem, oldElem interface{}) {
+	elemNode, ok := elem.(*Node)
+	if !ok {
+		return
+	}
+	if oldElemNode, ok := oldElem.(*Node); ok {
+		copyComments(elemNode, oldElemNode)
+	}
+}
+
+// copyObjectComments returns a new OrderedMap containing the elements from om
+// with comments copied from the matching elements in old, in the key order
+// from old.
+func copyObjectComments(om, old *OrderedMap) *OrderedMap {
+	// Key in om for each key in old.
+	matches := map[string]string{}
+	// Keys in om that have been matched to a key in old.
+	matched := map[string]bool{}
+	for _, key := range om.Keys {
+		if _, ok := old.Map[key]; ok {
+			matches[key] = key
+			matched[key] = true
+		}
+	}
+	for _, key := range om.Keys {
+		if matched[key] {
+			continue
+		}
+		for _, oldKey := range old.Keys {
+			if _, ok := matches[oldKey]; !ok && strings.EqualFold(key, oldKey) {
+				matches[oldKey] = key
+				matched[key] = true
+				break
+			}
+		}
+	}
+
+	res := NewOrderedMap()
+	for _, oldKey := range old.Keys {
+		if key, ok := matches[oldKey]; ok {
+			elem := om.Map[key]
+			copyElemComments(elem, old.Map[oldKey])
+			res.Set(oldKey, elem)
+		}
+	}
+	for _, key := range om.Keys {
+		if !matched[key] {
+			res.Set(key, om.Map[key])
+		}
+	}
+
+	return res
+}
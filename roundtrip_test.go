@@ -0,0 +1,176 @@
+package hjson
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalWithNode(t *testing.T) {
+	txt := []byte(`# Server configuration.
+{
+  # The name of the server.
+  name: main server
+
+  port: 8080 # Must be < 65536.
+  hosts: [
+    # Primary.
+    alpha
+    beta
+  ]
+  limits: {
+    cpu: 4
+  }
+}`)
+
+	type limits struct {
+		CPU    int
+		Memory int `json:"memory,omitempty" comment:"In MB."`
+	}
+	type config struct {
+		Port   int
+		Name   string
+		Hosts  []string
+		Limits limits
+	}
+
+	var cfg config
+	node, err := UnmarshalWithNode(txt, &cfg, DefaultDecoderOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8080 || cfg.Name != "main server" || len(cfg.Hosts) != 2 {
+		t.Fatalf("Unexpected values: %#v", cfg)
+	}
+
+	cfg.Port = 9090
+	cfg.Hosts = append(cfg.Hosts, "gamma")
+	cfg.Limits.Memory = 2048
+
+	h, err := MarshalWithNode(cfg, node, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, h, `# Server configuration.
+{
+  # The name of the server.
+  name: main server
+
+  port: 9090 # Must be < 65536.
+  hosts: [
+    # Primary.
+    alpha
+    beta
+    gamma
+  ]
+  limits: {
+    cpu: 4
+    # In MB.
+    memory: 2048
+  }
+}`)
+
+	// Without a node the output should be the same as from Marshal().
+	h, err = MarshalWithNode(cfg, nil, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, h, string(expected))
+}
+
+func TestMarshalWithNodeRawMessage(t *testing.T) {
+	type inner struct {
+		X   int
+		Raw RawMessage
+	}
+	type config struct {
+		Name  string
+		Inner inner
+	}
+
+	var cfg config
+	node, err := UnmarshalWithNode([]byte(`{
+  name: x
+  inner: {
+    x: 1
+  }
+}`), &cfg, DefaultDecoderOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The raw text is indented differently than the output, and the field is
+	// not found in node.
+	cfg.Inner.Raw = RawMessage("{\n  a: 1 # One.\n  deep: [\n        1\n     2\n  ]\n}")
+	h, err := MarshalWithNode(cfg, node, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, h, `{
+  name: x
+  inner: {
+    x: 1
+    Raw: {
+      a: 1 # One.
+      deep: [
+        1
+        2
+      ]
+    }
+  }
+}`)
+}
+
+func TestUnmarshalWithNodeTypes(t *testing.T) {
+	txt := []byte(`{
+  name: 3
+  flag: true
+  id: 9007199254740993
+  ptr: null
+  nullString: null
+  raw: {a: 1, b: [2, 3]}
+  time: 2006-01-02T15:04:05Z
+  any: 1.5
+  list: [
+    true
+    x
+  ]
+}`)
+
+	type config struct {
+		Name       string
+		Flag       string
+		ID         int64
+		Ptr        *string
+		NullString string
+		Raw        RawMessage
+		Time       time.Time
+		Any        interface{}
+		List       []string
+	}
+
+	// The values must be the same as from UnmarshalWithOptions(), even though
+	// the input is only parsed into a Node tree.
+	var expected config
+	if err := Unmarshal(txt, &expected); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	node, err := UnmarshalWithNode(txt, &cfg, DefaultDecoderOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected:\n%#v\n\nGot:\n%#v", expected, cfg)
+	}
+	if cfg.Name != "3" || cfg.ID != 9007199254740993 || string(cfg.Raw) != "{a: 1, b: [2, 3]}" {
+		t.Errorf("Unexpected values: %#v", cfg)
+	}
+	if node.NK("name").Value != 3.0 {
+		t.Errorf("Unexpected value in the Node tree: %#v", node.NK("name").Value)
+	}
+}
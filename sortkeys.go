@@ -0,0 +1,128 @@
+package hjson
+
+import (
+	"strings"
+)
+
+// SortKeys sorts the keys of the object in c, using less to compare keys, or
+// in alphabetical order if less is nil. The sort is stable. Each key is moved
+// together with its comments.
+//
+// Comments before the first key that are separated from that key by an empty
+// line are treated as a header for the whole object rather than as comments on
+// the key. The header stays first in the object, before the key that is first
+// after sorting.
+//
+// If recursive is true, all objects in the tree are sorted, including objects
+// in arrays. Otherwise only the object in c is sorted. Does nothing if c does
+// not contain an *OrderedMap.
+func (c *Node) SortKeys(recursive bool, less func(a, b string) bool) {
+	if c == nil {
+		return
+	}
+	if !recursive {
+		sortNodeKeys(c, less)
+		return
+	}
+	c.Walk(func(_ []PathElem, n *Node) error {
+		sortNodeKeys(n, less)
+		return nil
+	})
+}
+
+func sortNodeKeys(c *Node, less func(a, b string) bool) {
+	om, ok := c.Value.(*OrderedMap)
+	if !ok || len(om.Keys) < 2 {
+		return
+	}
+
+	var header, indent string
+	if first := elemNode(om.Map[om.Keys[0]]); first != nil && !first.Cm.isText(CommentBefore) {
+		header, first.Cm.Before = splitHeader(first.Cm.Before)
+		indent = first.Cm.Before[strings.LastIndex(first.Cm.Before, "\n")+1:]
+	}
+
+	om.SortKeys(less)
+
+	first := elemNode(om.Map[om.Keys[0]])
+	if header == "" {
+		// Empty lines separate a key from the previous key, they are not needed
+		// before the first key.
+		if first == nil {
+			return
+		}
+		if !first.Cm.isText(CommentBefore) {
+			first.Cm.Before = trimLeadingEmptyLines(first.Cm.Before)
+		} else if first.Cm.Before = strings.TrimLeft(first.Cm.Before, "\n"); first.Cm.Before == "" {
+			first.Cm.text &^= 1 << uint(CommentBefore)
+		}
+		return
+	}
+	if first == nil {
+		first = &Node{Value: om.Map[om.Keys[0]]}
+		om.Map[om.Keys[0]] = first
+	}
+	before := first.Cm.Before
+	if first.Cm.isText(CommentBefore) {
+		// The header already contains indentation, so the comment text must be
+		// indented in the same way.
+		before = indent + strings.Replace(before, "\n", "\n"+indent, -1) + "\n" + indent
+		first.Cm.text &^= 1 << uint(CommentBefore)
+	} else {
+		if before = trimLeadingEmptyLines(before); before == "" {
+			before = indent
+		}
+	}
+	first.Cm.Before = header + before
+}
+
+// splitHeader splits the Before comments of the first key in an object into a
+// header for the object and the comments for the key, at the last empty line
+// that is not inside a block comment.
+func splitHeader(before string) (header, rest string) {
+	lines := strings.SplitAfter(before, "\n")
+	inBlock := false
+	split := -1
+	// The last element is the indentation of the key, not a line.
+	for i, line := range lines[:len(lines)-1] {
+		if !inBlock && strings.TrimSpace(line) == "" {
+			split = i
+		}
+		inBlock = endsInBlockComment(line, inBlock)
+	}
+	if split < 0 {
+		return "", before
+	}
+	return strings.Join(lines[:split+1], ""), strings.Join(lines[split+1:], "")
+}
+
+// trimLeadingEmptyLines returns s without any empty lines at the start of s.
+func trimLeadingEmptyLines(s string) string {
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 || strings.TrimSpace(s[:i]) != "" {
+			return s
+		}
+		s = s[i+1:]
+	}
+}
+
+// endsInBlockComment returns true if line ends inside a block comment. inBlock
+// tells whether line starts inside a block comment.
+func endsInBlockComment(line string, inBlock bool) bool {
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inBlock:
+			if strings.HasPrefix(line[i:], "*/") {
+				inBlock = false
+				i++
+			}
+		case line[i] == '#' || strings.HasPrefix(line[i:], "//"):
+			return false
+		case strings.HasPrefix(line[i:], "/*"):
+			inBlock = true
+			i++
+		}
+	}
+	return inBlock
+}
@@ -0,0 +1,148 @@
+package hjson
+
+import (
+	"testing"
+)
+
+func TestNodeSortKeys(t *testing.T) {
+	txt := []byte(`# root
+{
+  # Section header
+
+  /* about
+
+  zeta */
+  zeta: 1
+  alpha: {
+    # first
+    d: 1
+    c: 2 # after c
+
+    # group
+    b: [{y: 1, x: 2}]
+  }
+  # last
+}`)
+
+	testCases := []struct {
+		recursive bool
+		less      func(a, b string) bool
+		expected  string
+	}{
+		{true, nil, `# root
+{
+  # Section header
+
+  alpha: {
+    # group
+    b: [
+      {
+        x: 2
+        y: 1
+      }
+    ]
+    c: 2 # after c
+    # first
+    d: 1
+  }
+  /* about
+
+  zeta */
+  zeta: 1
+  # last
+}`},
+		{false, nil, `# root
+{
+  # Section header
+
+  alpha: {
+    # first
+    d: 1
+    c: 2 # after c
+
+    # group
+    b: [
+      {
+        y: 1
+        x: 2
+      }
+    ]
+  }
+  /* about
+
+  zeta */
+  zeta: 1
+  # last
+}`},
+		{true, func(a, b string) bool { return a > b }, `# root
+{
+  # Section header
+
+  /* about
+
+  zeta */
+  zeta: 1
+  alpha: {
+    # first
+    d: 1
+    c: 2 # after c
+
+    # group
+    b: [
+      {
+        y: 1
+        x: 2
+      }
+    ]
+  }
+  # last
+}`},
+	}
+
+	for i, tc := range testCases {
+		var node *Node
+		if err := Unmarshal(txt, &node); err != nil {
+			t.Fatal(err)
+		}
+		node.SortKeys(tc.recursive, tc.less)
+		out, err := Marshal(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != tc.expected {
+			t.Errorf("%d: expected:\n%s\n\nGot:\n%s", i, tc.expected, out)
+		}
+	}
+}
+
+func TestNodeSortKeysHeader(t *testing.T) {
+	var node *Node
+	if err := Unmarshal([]byte("# header\n\n# about b\nb: 1\na: 2\nc: 3\n"), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultOptions()
+	options.EmitRootBraces = false
+	node.SortKeys(false, nil)
+	out, err := MarshalWithOptions(node, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# header\n\na: 2\n# about b\nb: 1\nc: 3"
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, out)
+	}
+
+	// Comments set by SetText() on the new first key are placed after the
+	// header.
+	node.NK("c").Cm.SetText(CommentBefore, "about c", CommentSlash)
+	node.SortKeys(false, func(a, b string) bool { return a > b })
+	out, err = MarshalWithOptions(node, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "# header\n\n// about c\nc: 3\n# about b\nb: 1\na: 2"
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, out)
+	}
+}
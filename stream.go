@@ -0,0 +1,283 @@
+package hjson
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// A Decoder reads and decodes Hjson values from an input stream.
+type Decoder struct {
+	r     io.Reader
+	buf   []byte // Data that has been read from r but not yet decoded.
+	rdErr error  // The first error returned by r.
+	err   error  // Sticky error, returned by all later calls to Decode().
+	opt   DecoderOptions
+	// The zero-based offset, line and column in the stream of the first byte in
+	// buf.
+	offset, line, column int
+}
+
+// NewDecoder returns a new Decoder that reads from r, using the options
+// returned by DefaultDecoderOptions().
+//
+// The Decoder introduces its own buffering and may read data from r beyond
+// the Hjson values requested.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:   r,
+		opt: DefaultDecoderOptions(),
+	}
+}
+
+// UseNumber causes the Decoder to unmarshal a number into an interface{} as a
+// json.Number instead of as a float64. See DecoderOptions.UseJSONNumber.
+func (dec *Decoder) UseNumber() {
+	dec.opt.UseJSONNumber = true
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when the
+// destination is a struct and the input contains object keys which do not
+// match any non-ignored, exported fields in the destination. See
+// DecoderOptions.DisallowUnknownFields.
+func (dec *Decoder) DisallowUnknownFields() {
+	dec.opt.DisallowUnknownFields = true
+}
+
+// DisallowDuplicateKeys causes the Decoder to return an error if an object
+// in the input contains duplicate keys. See
+// DecoderOptions.DisallowDuplicateKeys.
+func (dec *Decoder) DisallowDuplicateKeys() {
+	dec.opt.DisallowDuplicateKeys = true
+}
+
+// WhitespaceAsComments specifies if whitespace should be stored together with
+// comments when decoding into hjson.Node. Is true by default. See
+// DecoderOptions.WhitespaceAsComments.
+func (dec *Decoder) WhitespaceAsComments(on bool) {
+	dec.opt.WhitespaceAsComments = on
+}
+
+// RecoverFromErrors causes the Decoder to continue after syntax errors in
+// objects and arrays, and return all errors found in an ErrorList. See
+// DecoderOptions.RecoverFromErrors.
+func (dec *Decoder) RecoverFromErrors() {
+	dec.opt.RecoverFromErrors = true
+}
+
+// SetRecordPositions causes the Decoder to store the positions of the values
+// in Node.Pos when decoding into hjson.Node. The positions are counted from the
+// start of the stream. See DecoderOptions.RecordPositions.
+func (dec *Decoder) SetRecordPositions(on bool) {
+	dec.opt.RecordPositions = on
+}
+
+// SetMaxDepth sets the maximum nesting depth of objects and arrays. See
+// DecoderOptions.MaxDepth.
+func (dec *Decoder) SetMaxDepth(n int) {
+	dec.opt.MaxDepth = n
+}
+
+// SetMaxInputBytes sets the maximum number of bytes that can be buffered for
+// each call to Decode(). See DecoderOptions.MaxInputBytes.
+func (dec *Decoder) SetMaxInputBytes(n int) {
+	dec.opt.MaxInputBytes = n
+}
+
+// SetMaxStringLength sets the maximum length of strings and keys. See
+// DecoderOptions.MaxStringLength.
+func (dec *Decoder) SetMaxStringLength(n int) {
+	dec.opt.MaxStringLength = n
+}
+
+// SetMaxElements sets the maximum number of values in each decoded value. See
+// DecoderOptions.MaxElements.
+func (dec *Decoder) SetMaxElements(n int) {
+	dec.opt.MaxElements = n
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's buffer. The
+// reader is valid until the next call to Decode().
+func (dec *Decoder) Buffered() io.Reader {
+	return bytes.NewReader(dec.buf)
+}
+
+// Decode reads the next Hjson value from its input and stores it in the value
+// pointed to by v. Returns io.EOF if the input does not contain any more
+// values.
+//
+// A value enclosed in braces or brackets ends at its closing brace or
+// bracket, so that several such values can be read one by one from the same
+// stream. Any other value is first read as a root object without braces,
+// which extends to the end of the stream. If the rest of the stream is not
+// a root object, a single value such as a number, a string or true is read
+// instead, which ends where the next value begins. A quoteless string ends at
+// the end of its line. Comments and whitespace found after a value are treated
+// as the start of the next value.
+//
+// See the documentation for UnmarshalWithOptions() for details about the
+// conversion of Hjson into a Go value.
+func (dec *Decoder) Decode(v interface{}) error {
+	if dec.err != nil {
+		return dec.err
+	}
+	if _, err := checkDestination(v); err != nil {
+		return err
+	}
+
+	p := newHjsonParser(dec.buf, dec.opt)
+	p.rd = dec.r
+	p.stream = true
+	p.rdErr = dec.rdErr
+	p.baseOffset, p.baseLine, p.baseColumn = dec.offset, dec.line, dec.column
+	err := p.unmarshal(v, func(rv reflect.Value) (interface{}, error) {
+		p.resetAt()
+		ret, err := p.streamValue(rv)
+		if err != nil && err != io.EOF {
+			// We cannot know where the next value starts.
+			dec.err = err
+		}
+		return ret, err
+	})
+
+	dec.rdErr = p.rdErr
+	if dec.rdErr != nil && dec.rdErr != io.EOF {
+		dec.err = dec.rdErr
+		return dec.err
+	}
+
+	// Keep the unread data (starting with the current character) for the next
+	// call to Decode().
+	rest := p.at - 1
+	if rest > len(p.data) {
+		rest = len(p.data)
+	}
+	line, col, _ := p.position(rest)
+	if line > 1 {
+		dec.column = 0
+	}
+	dec.offset += rest
+	dec.line += line - 1
+	dec.column += col - 1
+	dec.buf = append(p.data[:0], p.data[rest:]...)
+
+	return err
+}
+
+// streamValue is like rootValue, except that it stops after the closing brace
+// or bracket if the value begins with an opening brace or bracket.
+func (p *hjsonParser) streamValue(dest reflect.Value) (ret interface{}, err error) {
+	ciBefore := p.white()
+
+	switch p.ch {
+	case 0:
+		return nil, io.EOF
+	case '{', '[':
+		dest = dest.Elem()
+		t := dest.Type()
+		start := p.at - 1
+		if p.ch == '{' {
+			ret, err = p.readObject(false, dest, t, ciBefore)
+		} else {
+			ret, err = p.readArray(dest, t)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !p.nodeDestination && isRawMessage(dest, t) {
+			ret = p.rawMessage(start)
+		}
+		p.setPos(ret, start)
+		if p.nodeDestination {
+			if node, ok := ret.(*Node); ok {
+				p.setComment1(&node.Cm.Before, ciBefore)
+			}
+		}
+		return ret, nil
+	}
+
+	p.resetAt()
+	return p.rootValue(dest)
+}
+
+// An Encoder writes Hjson values to an output stream.
+type Encoder struct {
+	w               io.Writer
+	opt             EncoderOptions
+	structTypeCache map[reflect.Type][]structFieldInfo
+}
+
+// NewEncoder returns a new Encoder that writes to w, using the options
+// returned by DefaultOptions().
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:               w,
+		opt:             DefaultOptions(),
+		structTypeCache: map[reflect.Type][]structFieldInfo{},
+	}
+}
+
+// SetEol sets the end of line string, should be either "\n" or "\r\n".
+func (enc *Encoder) SetEol(eol string) {
+	enc.opt.Eol = eol
+}
+
+// SetBracesSameLine specifies if braces should be placed on the same line as
+// their keys.
+func (enc *Encoder) SetBracesSameLine(on bool) {
+	enc.opt.BracesSameLine = on
+}
+
+// SetEmitRootBraces specifies if braces should be written for the root object.
+func (enc *Encoder) SetEmitRootBraces(on bool) {
+	enc.opt.EmitRootBraces = on
+}
+
+// SetQuoteAlways specifies if strings should always be placed in quotes.
+func (enc *Encoder) SetQuoteAlways(on bool) {
+	enc.opt.QuoteAlways = on
+}
+
+// SetQuoteAmbiguousStrings specifies if strings should be placed in quotes if
+// they could otherwise be read as a number, boolean or null.
+func (enc *Encoder) SetQuoteAmbiguousStrings(on bool) {
+	enc.opt.QuoteAmbiguousStrings = on
+}
+
+// SetIndent sets the base indentation string that begins each line, and the
+// string used for each level of indentation.
+func (enc *Encoder) SetIndent(baseIndentation, indentBy string) {
+	enc.opt.BaseIndentation = baseIndentation
+	enc.opt.IndentBy = indentBy
+}
+
+// SetComments specifies if comments should be written, if any are found in
+// hjson.Node structs or as tags on other structs.
+func (enc *Encoder) SetComments(on bool) {
+	enc.opt.Comments = on
+}
+
+// Encode writes the Hjson encoding of v to the stream, followed by an end of
+// line. The output is written to the stream in chunks while v is being
+// encoded, so if an error is returned some of the output might already have
+// been written to the stream.
+//
+// See the documentation for MarshalWithOptions() for details about the
+// conversion of Go values to Hjson.
+func (enc *Encoder) Encode(v interface{}) error {
+	e := &hjsonEncoder{
+		indent:         0,
+		EncoderOptions: enc.opt,
+		valueWalker: valueWalker{
+			structTypeCache: enc.structTypeCache,
+		},
+		w: enc.w,
+	}
+
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	e.WriteString(e.Eol)
+
+	return e.flush()
+}
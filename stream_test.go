@@ -0,0 +1,315 @@
+package hjson
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoderMultipleValues(t *testing.T) {
+	txt := `{a: 1, b: "x"}
+# Comment before the second value.
+[
+  1
+  '''
+  multi
+  line
+  '''
+]
+{"c": true}`
+
+	expected := []interface{}{
+		map[string]interface{}{"a": 1.0, "b": "x"},
+		[]interface{}{1.0, "multi\nline"},
+		map[string]interface{}{"c": true},
+	}
+
+	for _, r := range []io.Reader{
+		strings.NewReader(txt),
+		iotest.OneByteReader(strings.NewReader(txt)),
+	} {
+		dec := NewDecoder(r)
+		for i, exp := range expected {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("Value %d: %s", i, err)
+			}
+			if !reflect.DeepEqual(v, exp) {
+				t.Errorf("Value %d: Expected %#v, got %#v", i, exp, v)
+			}
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	}
+}
+
+func TestDecoderScalars(t *testing.T) {
+	txt := "1\n\"two\" # Comment.\ntrue\nquoteless string\n[5] 6\nx: 7\n"
+
+	expected := []interface{}{
+		1.0,
+		"two",
+		true,
+		"quoteless string",
+		[]interface{}{5.0},
+		6.0,
+		map[string]interface{}{"x": 7.0},
+	}
+
+	for _, r := range []io.Reader{
+		strings.NewReader(txt),
+		iotest.OneByteReader(strings.NewReader(txt)),
+	} {
+		dec := NewDecoder(r)
+		for i, exp := range expected {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("Value %d: %s", i, err)
+			}
+			if !reflect.DeepEqual(v, exp) {
+				t.Errorf("Value %d: Expected %#v, got %#v", i, exp, v)
+			}
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	}
+}
+
+func TestDecoderNonPointer(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("1\n2"))
+	var v int
+	if err := dec.Decode(v); err == nil {
+		t.Error("Expected an error for a non-pointer destination")
+	}
+	// The input is still available after the error.
+	if err := dec.Decode(&v); err != nil || v != 1 {
+		t.Errorf("Expected 1, got %d, %v", v, err)
+	}
+}
+
+func TestDecoderErrorPosition(t *testing.T) {
+	txt := "{\n  a: 1\n}\n[\n  2\n  3\n] {b: 4}\n{\n  c: 5\n  d: }\n}"
+
+	for _, r := range []io.Reader{
+		strings.NewReader(txt),
+		iotest.OneByteReader(strings.NewReader(txt)),
+	} {
+		dec := NewDecoder(r)
+		var v interface{}
+		for i := 0; i < 3; i++ {
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("Value %d: %s", i, err)
+			}
+		}
+		err := dec.Decode(&v)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("Expected a SyntaxError, got %v", err)
+		}
+		offset := strings.Index(txt, "d: }") + 3
+		if syntaxErr.Offset != offset || syntaxErr.Line != 10 || syntaxErr.Column != 6 {
+			t.Errorf("Expected the error at offset %d, line 10, column 6, got %d, %d, %d",
+				offset, syntaxErr.Offset, syntaxErr.Line, syntaxErr.Column)
+		}
+	}
+
+	// The column is counted from the start of the line, also for an error on the
+	// same line as the end of the previous value.
+	dec := NewDecoder(strings.NewReader("[1]\n[2] [3, }]"))
+	var v interface{}
+	for i := 0; i < 2; i++ {
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("Value %d: %s", i, err)
+		}
+	}
+	err := dec.Decode(&v)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 || syntaxErr.Column != 9 || syntaxErr.Offset != 12 {
+		t.Errorf("Unexpected error: %#v", err)
+	}
+}
+
+func TestDecoderRootWithoutBraces(t *testing.T) {
+	txt := `
+a: 1
+b: [2, 3]
+`
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(txt)))
+	var v struct {
+		A int
+		B []int
+	}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 1 || !reflect.DeepEqual(v.B, []int{2, 3}) {
+		t.Errorf("Unexpected value: %#v", v)
+	}
+	if err := dec.Decode(&v); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecoderNode(t *testing.T) {
+	txt := `# first
+{
+  a: 1 # one
+}
+{b: 2}`
+	dec := NewDecoder(strings.NewReader(txt))
+	var node Node
+	if err := dec.Decode(&node); err != nil {
+		t.Fatal(err)
+	}
+	if node.Cm.Before != "# first\n" {
+		t.Errorf("Unexpected comment before: %q", node.Cm.Before)
+	}
+	if node.NK("a").Cm.After != " # one" {
+		t.Errorf("Unexpected comment after: %q", node.NK("a").Cm.After)
+	}
+	if err := dec.Decode(&node); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := node.AtKey("b"); !ok {
+		t.Errorf("Key 'b' not found in the second value")
+	}
+}
+
+func TestDecoderRecordPositions(t *testing.T) {
+	txt := "{a: 1}\n[\n  2\n] {\n  b: x\n}"
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(txt)))
+	dec.SetRecordPositions(true)
+
+	type posCheck struct {
+		path       string
+		start, end Position
+	}
+	expected := [][]posCheck{
+		{{"/a", Position{4, 1, 5}, Position{5, 1, 6}}},
+		{{"", Position{7, 2, 1}, Position{14, 4, 2}}, {"/0", Position{11, 3, 3}, Position{12, 3, 4}}},
+		{{"", Position{15, 4, 3}, Position{25, 6, 2}}, {"/b", Position{22, 5, 6}, Position{23, 5, 7}}},
+	}
+	for i, checks := range expected {
+		var node *Node
+		if err := dec.Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		for _, exp := range checks {
+			elem, err := node.Get(exp.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elem.Pos == nil || elem.Pos.Value.Start != exp.start || elem.Pos.Value.End != exp.end {
+				t.Errorf("%d %q: Expected %v - %v, got %+v", i, exp.path, exp.start, exp.end, elem.Pos)
+			}
+		}
+	}
+}
+
+func TestDecoderOptions(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{a: 1}{b: 2, b: 3}`))
+	dec.UseNumber()
+	dec.DisallowDuplicateKeys()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := v.(map[string]interface{})["a"].(json.Number); !ok || n != "1" {
+		t.Errorf("Expected json.Number, got %#v", v)
+	}
+	err := dec.Decode(&v)
+	if err == nil {
+		t.Fatal("Expected error for duplicate keys")
+	}
+	if err2 := dec.Decode(&v); err2 != err {
+		t.Errorf("Expected the same error again, got %v", err2)
+	}
+}
+
+func TestDecoderReadError(t *testing.T) {
+	readErr := errors.New("read failed")
+	dec := NewDecoder(iotest.TimeoutReader(strings.NewReader(`{a: 1}`)))
+	var v interface{}
+	// iotest.TimeoutReader returns data on the first read and an error on the
+	// second read.
+	if err := dec.Decode(&v); err != iotest.ErrTimeout {
+		t.Errorf("Expected %v, got %v", iotest.ErrTimeout, err)
+	}
+
+	dec = NewDecoder(iotest.ErrReader(readErr))
+	if err := dec.Decode(&v); err != readErr {
+		t.Errorf("Expected %v, got %v", readErr, err)
+	}
+}
+
+type countingWriter struct {
+	writes int
+	out    []byte
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.out = append(w.out, p...)
+	return len(p), nil
+}
+
+func TestEncoder(t *testing.T) {
+	var w countingWriter
+	enc := NewEncoder(&w)
+	enc.SetIndent("", "\t")
+	enc.SetEmitRootBraces(false)
+	if err := enc.Encode(map[string]interface{}{"a": 1, "b": []int{2}}); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetEmitRootBraces(true)
+	enc.SetBracesSameLine(false)
+	if err := enc.Encode(struct{ C map[string]int }{map[string]int{"d": 3}}); err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, w.out, "a: 1\nb: [\n\t2\n]\n{\n\tC:\n\t{\n\t\td: 3\n\t}\n}\n")
+}
+
+func TestEncoderFlush(t *testing.T) {
+	var arr []string
+	for i := 0; i < 10000; i++ {
+		arr = append(arr, strings.Repeat("x", 20))
+	}
+
+	var w countingWriter
+	if err := NewEncoder(&w).Encode(arr); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes < 2 {
+		t.Errorf("Expected the output to be written in several chunks, got %d", w.writes)
+	}
+
+	expected, err := Marshal(arr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStrings(t, w.out, string(expected)+"\n")
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	enc := NewEncoder(errWriter{writeErr})
+	if err := enc.Encode([]int{1}); err != writeErr {
+		t.Errorf("Expected %v, got %v", writeErr, err)
+	}
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
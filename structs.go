@@ -1,7 +1,6 @@
 package hjson
 
 import (
-	"fmt"
 	"reflect"
 	"sort"
 	"strings"
@@ -18,7 +17,13 @@ type structFieldInfo struct {
 	tagged    bool
 	comment   string
 	omitEmpty bool
-	indexPath []int
+	// quoted is true for fields tagged with the ",string" option, whose values
+	// are stored as JSON strings.
+	quoted bool
+	// defaultValue is the Hjson text in the "default" struct tag, stored in the
+	// field by Unmarshal() if the key of the field is missing in the input.
+	defaultValue string
+	indexPath    []int
 }
 
 // Use lower key name as key. Values are arrays in case some fields only differ
@@ -132,8 +137,9 @@ func getStructFieldInfo(rootType reflect.Type) []structFieldInfo {
 				}
 
 				sfi := structFieldInfo{
-					name:    sf.Name,
-					comment: sf.Tag.Get("comment"),
+					name:         sf.Name,
+					comment:      sf.Tag.Get("comment"),
+					defaultValue: sf.Tag.Get("default"),
 				}
 
 				splits := strings.Split(jsonTag, ",")
@@ -141,10 +147,14 @@ func getStructFieldInfo(rootType reflect.Type) []structFieldInfo {
 					sfi.name = splits[0]
 					sfi.tagged = true
 				}
+				quoted := false
 				if len(splits) > 1 {
 					for _, opt := range splits[1:] {
-						if opt == "omitempty" {
+						switch opt {
+						case "omitempty":
 							sfi.omitEmpty = true
+						case "string":
+							quoted = true
 						}
 					}
 				}
@@ -159,6 +169,18 @@ func getStructFieldInfo(rootType reflect.Type) []structFieldInfo {
 					ft = ft.Elem()
 				}
 
+				if quoted {
+					// Only strings, floats, integers, and booleans can be quoted.
+					switch ft.Kind() {
+					case reflect.Bool,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64,
+						reflect.String:
+						sfi.quoted = true
+					}
+				}
+
 				// If the current field should be included.
 				if sfi.tagged || !sf.Anonymous || ft.Kind() != reflect.Struct {
 					sfis = append(sfis, sfi)
@@ -239,6 +261,54 @@ func getStructFieldInfoSlice(rootType reflect.Type) []structFieldInfo {
 	return sfis
 }
 
+// structDefault is a struct field with a "default" struct tag, or a field of a
+// struct type that contains fields with default values.
+type structDefault struct {
+	structFieldInfo
+	// value is the result of parsing defaultValue, or err the error from
+	// parsing it. Both are nil if defaultValue is empty.
+	value interface{}
+	err   error
+}
+
+// getStructDefaults returns the fields of the struct type rootType that have a
+// "default" struct tag, sorted by index, with the default values parsed by
+// parse. Fields of struct types that contain fields with default values are
+// also returned, so that the defaults can be set for those fields too.
+func getStructDefaults(
+	rootType reflect.Type,
+	parse func(text string, t reflect.Type) (interface{}, error),
+) []structDefault {
+	var out []structDefault
+	for _, sfi := range getStructFieldInfoSlice(rootType) {
+		sd := structDefault{structFieldInfo: sfi}
+		ft := rootType.FieldByIndex(sfi.indexPath).Type
+		if sfi.defaultValue != "" {
+			sd.value, sd.err = parse(sfi.defaultValue, ft)
+		} else if ft.Kind() != reflect.Struct || !hasStructDefaults(ft) {
+			continue
+		}
+		out = append(out, sd)
+	}
+
+	return out
+}
+
+// hasStructDefaults returns true if the struct type t has fields with a
+// "default" struct tag, directly or in fields of struct types.
+func hasStructDefaults(t reflect.Type) bool {
+	for _, sfi := range getStructFieldInfoSlice(t) {
+		if sfi.defaultValue != "" {
+			return true
+		}
+		ft := t.FieldByIndex(sfi.indexPath).Type
+		if ft.Kind() == reflect.Struct && hasStructDefaults(ft) {
+			return true
+		}
+	}
+	return false
+}
+
 func getStructFieldInfoMap(rootType reflect.Type) structFieldMap {
 	sfis := getStructFieldInfo(rootType)
 
@@ -264,6 +334,7 @@ func (e *hjsonEncoder) writeFields(
 		e.WriteString("{" + cm.InsideFirst)
 
 		if len(fis) == 0 {
+			cm.InsideLast = e.commentString(cm, CommentInsideLast, e.indent+1, e.indent)
 			if cm.InsideFirst != "" || cm.InsideLast != "" {
 				e.WriteString(e.Eol)
 			}
@@ -284,23 +355,28 @@ func (e *hjsonEncoder) writeFields(
 		}
 
 		e.indent++
+		cm.InsideLast = e.commentString(cm, CommentInsideLast, e.indent, indent1)
 	} else {
 		e.WriteString(cm.InsideFirst)
+		cm.InsideLast = e.commentString(cm, CommentInsideLast, e.indent, -1)
 	}
 
 	// Join all of the member texts together, separated with newlines
-	var elemCm Comments
 	for i, fi := range fis {
-		var elem reflect.Value
-		elem, elemCm = e.unpackNode(fi.field, elemCm)
+		elem, elemCm, err := e.unpackNode(fi.field, Comments{})
+		if err != nil {
+			return err
+		}
+		elemCm.Before = e.commentString(elemCm, CommentBefore, e.indent, e.indent)
+		elemCm.Key = e.commentString(elemCm, CommentKey, e.indent, e.indent+1)
 		if i > 0 || !isRootObject || e.EmitRootBraces {
 			e.WriteString(e.Eol)
 		}
-		if len(fi.comment) > 0 {
-			for _, line := range strings.Split(fi.comment, e.Eol) {
-				e.writeIndentNoEOL(e.indent)
-				e.WriteString(fmt.Sprintf("# %s\n", line))
-			}
+		comment := e.Comments && len(fi.comment) > 0
+		if comment {
+			var tagCm Comments
+			tagCm.SetText(CommentBefore, fi.comment, CommentHash)
+			e.WriteString(e.commentString(tagCm, CommentBefore, e.indent, -1))
 		}
 		if elemCm.Before == "" {
 			e.writeIndentNoEOL(e.indent)
@@ -315,11 +391,15 @@ func (e *hjsonEncoder) writeFields(
 			return err
 		}
 
-		if len(fi.comment) > 0 && i < len(fis)-1 {
+		if comment && i < len(fis)-1 {
 			e.WriteString(e.Eol)
 		}
 
 		e.WriteString(elemCm.After)
+
+		if err := e.flushIfFull(); err != nil {
+			return err
+		}
 	}
 
 	if cm.InsideLast != "" {
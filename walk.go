@@ -0,0 +1,211 @@
+package hjson
+
+import (
+	"errors"
+	"strconv"
+)
+
+// PathElem is one step in the path from the root of a Node tree to a Node.
+type PathElem struct {
+	// Key is the key of the Node, if the parent is an object.
+	Key string
+	// Index is the position of the Node in the parent object or array.
+	Index int
+	// IsKey is true if the parent is an object, false if it is an array.
+	IsKey bool
+}
+
+// String returns the key or the index of the path element.
+func (e PathElem) String() string {
+	if e.IsKey {
+		return e.Key
+	}
+	return strconv.Itoa(e.Index)
+}
+
+// PathPointer returns the JSON Pointer (RFC 6901) for path, that can be
+// passed to Node.Get().
+func PathPointer(path []PathElem) string {
+	tokens := make([]string, len(path))
+	for i, elem := range path {
+		tokens[i] = elem.String()
+	}
+	return formatPointer(tokens)
+}
+
+// SkipChildren can be returned by a WalkFunc or a TransformFunc to skip the
+// children of the current Node. When returned from a WalkFunc called by
+// Node.WalkPostOrder(), it has the same effect as returning nil.
+var SkipChildren = errors.New("Skip children")
+
+// SkipAll can be returned by a WalkFunc or a TransformFunc to stop the walk
+// without returning an error.
+var SkipAll = errors.New("Skip all")
+
+// WalkFunc is the type of the function called by Node.Walk() and
+// Node.WalkPostOrder() for each Node in a tree. path is the path from the
+// root of the walk to n, it is empty for the root. The path slice is reused
+// between calls and must not be retained.
+//
+// If the function returns an error other than SkipChildren or SkipAll, the
+// walk is stopped and the error is returned.
+type WalkFunc func(path []PathElem, n *Node) error
+
+// Walk calls fn for c and for every descendant Node of c, in pre-order: each
+// Node is visited before its children, and the children of an object or an
+// array are visited in order. Elements of objects and arrays that are not of
+// type *Node are not visited.
+//
+// If fn returns SkipChildren, the children of that Node are not visited. If fn
+// returns SkipAll, no more Nodes are visited and Walk returns nil. Any other
+// error stops the walk and is returned by Walk.
+func (c *Node) Walk(fn WalkFunc) error {
+	if c == nil {
+		return nil
+	}
+	err := c.walk(nil, fn, false)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+// WalkPostOrder is like Walk, but each Node is visited after its children.
+func (c *Node) WalkPostOrder(fn WalkFunc) error {
+	if c == nil {
+		return nil
+	}
+	err := c.walk(nil, fn, true)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (c *Node) walk(path []PathElem, fn WalkFunc, postOrder bool) error {
+	if !postOrder {
+		if err := fn(path, c); err == SkipChildren {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	err := c.forEachChild(func(elem PathElem, child *Node) error {
+		return child.walk(append(path, elem), fn, postOrder)
+	})
+	if err != nil {
+		return err
+	}
+
+	if postOrder {
+		if err := fn(path, c); err != SkipChildren {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachChild calls fn for each element of type *Node in c, in order, until
+// fn returns an error.
+func (c *Node) forEachChild(fn func(PathElem, *Node) error) error {
+	switch cont := c.Value.(type) {
+	case *OrderedMap:
+		for i, key := range cont.Keys {
+			if child := elemNode(cont.Map[key]); child != nil {
+				if err := fn(PathElem{Key: key, Index: i, IsKey: true}, child); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		for i, elem := range cont {
+			if child := elemNode(elem); child != nil {
+				if err := fn(PathElem{Index: i}, child); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// TransformFunc is the type of the function called by Node.Transform() for
+// each Node in a tree. It returns the Node to use instead of n, which can be n
+// itself (possibly modified), a new Node, or nil to delete n from its parent.
+// See WalkFunc for details about path and the returned error.
+type TransformFunc func(path []PathElem, n *Node) (*Node, error)
+
+// Transform calls fn for c and for every descendant Node of c, in pre-order
+// like Walk, and replaces each Node with the Node returned by fn. The
+// children of the returned Node are visited next, unless fn returns
+// SkipChildren. A Node for which fn returns nil is deleted from its parent
+// object or array. In the path passed to fn, PathElem.Index is the position of
+// the element before any elements were deleted from the parent.
+//
+// Transform returns the new root, which is nil if fn returned nil for c. If fn
+// returns an error other than SkipChildren or SkipAll, the transform is
+// stopped and the error is returned. The tree may then be partially
+// transformed.
+func (c *Node) Transform(fn TransformFunc) (*Node, error) {
+	if c == nil {
+		return nil, nil
+	}
+	res, err := c.transform(nil, fn)
+	if err == SkipAll {
+		err = nil
+	}
+	return res, err
+}
+
+// transform returns the replacement for c. If the error is SkipAll, the
+// returned Node is still valid.
+func (c *Node) transform(path []PathElem, fn TransformFunc) (*Node, error) {
+	res, err := fn(path, c)
+	if err == SkipChildren {
+		return res, nil
+	}
+	if err != nil || res == nil {
+		return res, err
+	}
+
+	switch cont := res.Value.(type) {
+	case *OrderedMap:
+		keys := append([]string(nil), cont.Keys...)
+		for i, key := range keys {
+			child := elemNode(cont.Map[key])
+			if child == nil {
+				continue
+			}
+			newChild, err := child.transform(append(path, PathElem{Key: key, Index: i, IsKey: true}), fn)
+			if newChild == nil {
+				cont.DeleteKey(key)
+			} else {
+				cont.Map[key] = newChild
+			}
+			if err != nil {
+				return res, err
+			}
+		}
+
+	case []interface{}:
+		arr := cont[:0]
+		for i, elem := range cont {
+			child := elemNode(elem)
+			if child != nil && err == nil {
+				child, err = child.transform(append(path, PathElem{Index: i}), fn)
+				if child == nil {
+					continue
+				}
+				elem = child
+			}
+			arr = append(arr, elem)
+		}
+		res.Value = arr
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,157 @@
+package hjson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var walkText = `{
+  name: app
+  servers: [
+    {host: "a", port: 1}
+    {host: "b", port: 2}
+  ]
+  log: {
+    level: info
+  }
+}`
+
+func walkPaths(t *testing.T, node *Node, postOrder bool, fn WalkFunc) string {
+	var paths []string
+	walkFn := func(path []PathElem, n *Node) error {
+		paths = append(paths, PathPointer(path))
+		return fn(path, n)
+	}
+	var err error
+	if postOrder {
+		err = node.WalkPostOrder(walkFn)
+	} else {
+		err = node.Walk(walkFn)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(paths, " ")
+}
+
+func TestNodeWalk(t *testing.T) {
+	node := unmarshalNode(t, walkText)
+	noop := func(path []PathElem, n *Node) error { return nil }
+
+	testCases := []struct {
+		postOrder bool
+		fn        WalkFunc
+		expected  string
+	}{
+		{false, noop, " /name /servers /servers/0 /servers/0/host /servers/0/port /servers/1 /servers/1/host /servers/1/port /log /log/level"},
+		{true, noop, "/name /servers/0/host /servers/0/port /servers/0 /servers/1/host /servers/1/port /servers/1 /servers /log/level /log "},
+		{false, func(path []PathElem, n *Node) error {
+			if len(path) == 1 && path[0].Key == "servers" {
+				return SkipChildren
+			}
+			return nil
+		}, " /name /servers /log /log/level"},
+		{false, func(path []PathElem, n *Node) error {
+			if n.Value == "b" {
+				return SkipAll
+			}
+			return nil
+		}, " /name /servers /servers/0 /servers/0/host /servers/0/port /servers/1 /servers/1/host"},
+		{true, func(path []PathElem, n *Node) error {
+			if len(path) == 2 {
+				return SkipAll
+			}
+			return nil
+		}, "/name /servers/0/host /servers/0/port /servers/0"},
+	}
+
+	for i, tc := range testCases {
+		if res := walkPaths(t, node, tc.postOrder, tc.fn); res != tc.expected {
+			t.Errorf("%d: Expected:\n%s\nGot:\n%s", i, tc.expected, res)
+		}
+	}
+
+	// Errors are returned, and the path elements describe the position.
+	walkErr := errors.New("walk failed")
+	err := node.Walk(func(path []PathElem, n *Node) error {
+		if n.Value == 2.0 {
+			last := path[len(path)-1]
+			if !last.IsKey || last.Key != "port" || last.Index != 1 || path[1].IsKey || path[1].Index != 1 {
+				t.Errorf("Unexpected path %v", path)
+			}
+			return walkErr
+		}
+		return nil
+	})
+	if err != walkErr {
+		t.Errorf("Expected %v, got %v", walkErr, err)
+	}
+}
+
+func TestNodeTransform(t *testing.T) {
+	node := unmarshalNode(t, walkText)
+
+	res, err := node.Transform(func(path []PathElem, n *Node) (*Node, error) {
+		switch {
+		case len(path) > 0 && path[len(path)-1].Key == "port":
+			// Delete all ports.
+			return nil, nil
+		case n.Value == "info":
+			return &Node{Value: "debug", Cm: Comments{After: " # changed"}}, nil
+		case len(path) == 1 && path[0].Key == "name":
+			n.Value = strings.ToUpper(n.Value.(string))
+			return n, SkipChildren
+		}
+		if len(path) == 2 && path[1].Index == 0 {
+			// Delete the first server.
+			return nil, nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != node {
+		t.Error("Expected the same root")
+	}
+
+	expected := `{
+  name: APP
+  servers: [
+    {
+      host: b
+    }
+  ]
+  log: {
+    level: "debug" # changed
+  }
+}`
+	b, err := Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, string(b))
+	}
+
+	// SkipAll stops the transform but keeps the replaced node.
+	node = unmarshalNode(t, walkText)
+	if _, err = node.Transform(func(path []PathElem, n *Node) (*Node, error) {
+		if n.Value == "app" {
+			return &Node{Value: "x"}, SkipAll
+		}
+		return n, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if node.NK("name").Value != "x" || node.NK("log").NK("level").Value != "info" {
+		t.Errorf("Unexpected result: %v", node)
+	}
+
+	if res, err = node.Transform(func(path []PathElem, n *Node) (*Node, error) {
+		return nil, nil
+	}); res != nil || err != nil {
+		t.Errorf("Expected nil, got %v %v", res, err)
+	}
+}